@@ -0,0 +1,245 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	artEnabled = flag.Bool("mpris-art", false, "cache album art")
+	artDir = flag.String(
+		"mpris-art-dir",
+		"",
+		"album art cache directory (default $XDG_CACHE_HOME/gostatuses/art)",
+	)
+	artHttp = flag.Bool(
+		"mpris-art-http",
+		false,
+		"download album art from http(s) urls",
+	)
+	artMaxSize = flag.Int64(
+		"mpris-art-max-size",
+		64,
+		"maximum size of album art cache, in MiB",
+	)
+	artMaxAge = flag.Duration(
+		"mpris-art-max-age",
+		30 * 24 * time.Hour,
+		"maximum age of cached album art",
+	)
+)
+
+const artMaxFileSize = 16 << 20
+
+// artCache copies album art into a local directory, evicting files by age
+// and total size.
+type artCache struct {
+	dir string
+	maxSize int64
+	maxAge time.Duration
+	client *http.Client
+}
+
+func newArtCache() (c *artCache, err error) {
+	dir := *artDir
+	if len(dir) == 0 {
+		cache, e := os.UserCacheDir()
+		if e != nil {
+			err = e
+			return
+		}
+		dir = filepath.Join(cache, "gostatuses", "art")
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	c = &artCache{
+		dir: dir,
+		maxSize: *artMaxSize << 20,
+		maxAge: *artMaxAge,
+	}
+	if *artHttp {
+		c.client = &http.Client{Timeout: 10 * time.Second}
+	}
+	// expire the art cached by previous runs
+	c.evict()
+	return
+}
+
+func (c *artCache) pathFor(artUrl string) string {
+	sum := sha1.Sum([]byte(artUrl))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+// open returns a reader on the art designated by artUrl.
+func (c *artCache) open(artUrl string) (r io.ReadCloser, err error) {
+	u, err := url.Parse(artUrl)
+	if err != nil {
+		return
+	}
+	switch u.Scheme {
+	case `file`:
+		return os.Open(u.Path)
+	case ``:
+		return os.Open(artUrl)
+	case `data`:
+		// data:[<mediatype>][;base64],<data>
+		tokens := strings.SplitN(u.Opaque, ",", 2)
+		if len(tokens) != 2 || !(strings.HasSuffix(tokens[0], ";base64")) {
+			return nil, fmt.Errorf("unsupported data url")
+		}
+		decoder := base64.NewDecoder(
+			base64.StdEncoding,
+			strings.NewReader(tokens[1]),
+		)
+		return ioutil.NopCloser(decoder), nil
+	case `http`, `https`:
+		if c.client == nil {
+			return nil, fmt.Errorf("http album art disabled")
+		}
+		resp, e := c.client.Get(artUrl)
+		if e != nil {
+			return nil, e
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%s: %s", artUrl, resp.Status)
+		}
+		return resp.Body, nil
+	}
+	return nil, fmt.Errorf("unsupported url scheme: %s", u.Scheme)
+}
+
+// resolve returns the path of the cached copy of the art, fetching it when
+// required.
+func (c *artCache) resolve(artUrl string) (path string, err error) {
+	path = c.pathFor(artUrl)
+	now := time.Now()
+	if _, e := os.Stat(path); e == nil {
+		os.Chtimes(path, now, now)
+		return
+	}
+	r, err := c.open(artUrl)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	tmp, err := ioutil.TempFile(c.dir, ".art-")
+	if err != nil {
+		return "", err
+	}
+	// read one more byte to tell oversized art from art of the maximum size
+	n, err := io.Copy(tmp, io.LimitReader(r, artMaxFileSize + 1))
+	if err == nil && n > artMaxFileSize {
+		err = fmt.Errorf("album art larger than %d bytes", artMaxFileSize)
+	}
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	c.evict()
+	return
+}
+
+// evict removes files older than maxAge, then the least recently used ones
+// until the cache fits in maxSize.
+func (c *artCache) evict() {
+	entries, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	var kept []os.FileInfo
+	var total int64
+	for _, info := range entries {
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+			continue
+		}
+		if c.maxAge > 0 && time.Since(info.ModTime()) > c.maxAge {
+			os.Remove(filepath.Join(c.dir, info.Name()))
+			continue
+		}
+		kept = append(kept, info)
+		total += info.Size()
+	}
+	sort.Slice(kept, func(i, j int) bool {
+		return kept[i].ModTime().Before(kept[j].ModTime())
+	})
+	for _, info := range kept {
+		if c.maxSize <= 0 || total <= c.maxSize {
+			break
+		}
+		os.Remove(filepath.Join(c.dir, info.Name()))
+		total -= info.Size()
+	}
+}
+
+// artRetryDelay is the time to wait before resolving again an album art that
+// failed, like a file not written yet by the player.
+const artRetryDelay = 30 * time.Second
+
+// artPath returns the cached path of artUrl when already resolved, or starts
+// resolving it and publishes the metadata again when done.
+func (l *Listener) artPath(artUrl string) string {
+	if l.art == nil || len(artUrl) == 0 {
+		return ""
+	}
+	if path, found := l.artPaths[artUrl]; found {
+		// still resolving
+		if len(path) == 0 {
+			return ""
+		}
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		// evicted from the cache
+		delete(l.artPaths, artUrl)
+	}
+	if failed, found := l.artFailures[artUrl]; found &&
+		time.Since(failed) < artRetryDelay {
+		return ""
+	}
+	if len(l.artPaths) > 64 {
+		l.artPaths = make(map[string]string)
+	}
+	if len(l.artFailures) > 64 {
+		l.artFailures = make(map[string]time.Time)
+	}
+	l.artPaths[artUrl] = ""
+	go func() {
+		path, err := l.art.resolve(artUrl)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "failed to cache album art:", err)
+		}
+		l.do(func() {
+			if err != nil {
+				delete(l.artPaths, artUrl)
+				l.artFailures[artUrl] = time.Now()
+				return
+			}
+			delete(l.artFailures, artUrl)
+			l.artPaths[artUrl] = path
+			l.publishMetadata()
+		})
+	}()
+	return ""
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
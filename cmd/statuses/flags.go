@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// keyValues is a repeatable flag of key=value pairs.
+type keyValues map[string]string
+
+func (kv keyValues) String() string {
+	var items []string
+	for key, value := range kv {
+		items = append(items, key + "=" + value)
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+func (kv keyValues) Set(s string) error {
+	tokens := strings.SplitN(s, "=", 2)
+	if len(tokens) != 2 || len(tokens[0]) == 0 {
+		return fmt.Errorf("expecting key=value, got %q", s)
+	}
+	kv[tokens[0]] = tokens[1]
+	return nil
+}
+
+// stringList is a repeatable flag of strings.
+type stringList []string
+
+func (sl *stringList) String() string {
+	return strings.Join(*sl, ",")
+}
+
+func (sl *stringList) Set(s string) error {
+	*sl = append(*sl, s)
+	return nil
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
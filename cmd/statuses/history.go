@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+	"github.com/godbus/dbus/v5"
+	mpris "github.com/Pauloo27/go-mpris"
+)
+
+var (
+	historyPath = flag.String(
+		"mpris-history",
+		"",
+		"append played tracks to this JSON lines file",
+	)
+	historyMin = flag.Duration(
+		"mpris-history-min",
+		30 * time.Second,
+		"minimum playing time for a track to be recorded",
+	)
+)
+
+var errHistoryDisabled = `com.github.canalguada.gostatuses.Mpris.HistoryDisabled`
+
+// HistoryEntry is a played track, as written in the history file and
+// returned by the History method.
+type HistoryEntry struct {
+	Timestamp int64 `json:"timestamp"`
+	Player string `json:"player"`
+	Artist string `json:"artist,omitempty"`
+	Title string `json:"title,omitempty"`
+	Album string `json:"album,omitempty"`
+	Played int64 `json:"played"`
+}
+
+// playedTrack accumulates the time a track is actually playing.
+type playedTrack struct {
+	entry HistoryEntry
+	key string
+	since time.Time
+	played time.Duration
+}
+
+func (t *playedTrack) setPlaying(playing bool, now time.Time) {
+	switch {
+	case playing && t.since.IsZero():
+		t.since = now
+	case !(playing) && !(t.since.IsZero()):
+		t.played += now.Sub(t.since)
+		t.since = time.Time{}
+	}
+}
+
+// historyRecorder appends played tracks to a JSON lines file.
+type historyRecorder struct {
+	path string
+	min time.Duration
+	tracks map[string]*playedTrack
+}
+
+func newHistoryRecorder(path string, min time.Duration) *historyRecorder {
+	return &historyRecorder{
+		path: path,
+		min: min,
+		tracks: make(map[string]*playedTrack),
+	}
+}
+
+func trackKey(p *Properties) string {
+	if id := p.Field(`trackid`); len(id) > 0 {
+		return id
+	}
+	return p.GetArtist() + "\x00" + p.GetTitle() + "\x00" + p.GetUrl()
+}
+
+// update follows the playback status and the track of the player owned by
+// owner, and records the previous track when it changes.
+func (h *historyRecorder) update(owner string, p *Properties) {
+	now := time.Now()
+	key := trackKey(p)
+	if t, found := h.tracks[owner]; found && t.key != key {
+		h.finish(owner)
+	}
+	t, found := h.tracks[owner]
+	if !(found) {
+		t = &playedTrack{
+			key: key,
+			entry: HistoryEntry{
+				Timestamp: now.Unix(),
+				Player: p.Player,
+				Artist: p.GetArtist(),
+				Title: p.GetTitle(),
+				Album: p.Field(`album`),
+			},
+		}
+		h.tracks[owner] = t
+	}
+	t.setPlaying(p.PlaybackStatus == mpris.PlaybackPlaying, now)
+}
+
+// finish records the current track of the player owned by owner, when it
+// has been played long enough.
+func (h *historyRecorder) finish(owner string) {
+	t, found := h.tracks[owner]
+	if !(found) {
+		return
+	}
+	delete(h.tracks, owner)
+	t.setPlaying(false, time.Now())
+	if t.played < h.min || len(t.entry.Title) == 0 {
+		return
+	}
+	t.entry.Played = int64(t.played / time.Second)
+	if err := h.write(t.entry); err != nil {
+		fmt.Fprintln(os.Stderr, "failed to write history:", err)
+	}
+}
+
+func (h *historyRecorder) finishAll() {
+	for owner := range h.tracks {
+		h.finish(owner)
+	}
+}
+
+func (h *historyRecorder) write(entry HistoryEntry) (err error) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return
+}
+
+// recent returns at most count entries, the most recent first.
+func (h *historyRecorder) recent(count int) (entries []HistoryEntry, err error) {
+	f, err := os.Open(h.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return
+	}
+	defer f.Close()
+	var all []HistoryEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry HistoryEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			all = append(all, entry)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+	for i := len(all) - 1; i >= 0 && len(entries) < count; i-- {
+		entries = append(entries, all[i])
+	}
+	return
+}
+
+func (l *Listener) trackHistory(owner string) {
+	if l.history == nil {
+		return
+	}
+	if p, err := l.getProperties(owner); err == nil {
+		l.history.update(owner, &p)
+	}
+}
+
+func (l *Listener) finishHistory(owner string) {
+	if l.history != nil {
+		l.history.finish(owner)
+	}
+}
+
+// redact removes the track names from the entries, in privacy mode.
+func redact(entries []HistoryEntry) {
+	for i := range entries {
+		entries[i].Artist = ""
+		entries[i].Title = ""
+		entries[i].Album = ""
+	}
+}
+
+// History returns at most count recently played tracks, the most recent
+// first, without their names while privacy mode is on.
+func (c MprisControl) History(count int32) (
+	entries []HistoryEntry,
+	err *dbus.Error,
+) {
+	c.l.do(func() {
+		if c.l.history == nil {
+			err = dbus.NewError(
+				errHistoryDisabled,
+				[]interface{}{"history is disabled"},
+			)
+			return
+		}
+		var e error
+		if entries, e = c.l.history.recent(int(count)); e != nil {
+			err = dbus.MakeFailedError(e)
+			return
+		}
+		if c.l.isPrivate() {
+			redact(entries)
+		}
+	})
+	return
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
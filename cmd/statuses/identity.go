@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	mpris "github.com/Pauloo27/go-mpris"
+)
+
+var mprisPlayerIcons = make(keyValues)
+
+func init() {
+	flag.Var(
+		mprisPlayerIcons,
+		"mpris-player-icon",
+		"icon for a player, like 'spotify=' (repeatable)",
+	)
+}
+
+// playerIdentity holds the properties of the org.mpris.MediaPlayer2
+// interface, read once when the player appears.
+type playerIdentity struct {
+	Identity string
+	DesktopEntry string
+}
+
+func getPlayerIdentity(player playerReader) (id playerIdentity) {
+	if variant, err := player.GetProperty(mpris.BaseInterface, `Identity`); err == nil {
+		id.Identity, _ = variant.Value().(string)
+	}
+	if variant, err := player.GetProperty(mpris.BaseInterface, `DesktopEntry`); err == nil {
+		id.DesktopEntry, _ = variant.Value().(string)
+	}
+	return
+}
+
+// Matches reports whether key designates the player, either by short bus
+// name, like "spotify" or "firefox" for "firefox.instance42", by desktop
+// entry or by identity.
+func (p *Properties) Matches(key string) bool {
+	switch {
+	case p.Player == key, strings.HasPrefix(p.Player, key + "."):
+		return true
+	case len(p.DesktopEntry) > 0 && strings.EqualFold(p.DesktopEntry, key):
+		return true
+	case len(p.Identity) > 0 && strings.EqualFold(p.Identity, key):
+		return true
+	}
+	return false
+}
+
+// matchKey returns the most specific key designating the player, the
+// longest one, so that "firefox.instance42" wins over "firefox" whatever the
+// order of the keys.
+func (p *Properties) matchKey(keys []string) (key string, found bool) {
+	for _, k := range keys {
+		if !(p.Matches(k)) {
+			continue
+		}
+		if !(found) || len(k) > len(key) || (len(k) == len(key) && k < key) {
+			key, found = k, true
+		}
+	}
+	return
+}
+
+// GetPlayerIcon returns the icon configured for the player, if any.
+func (p *Properties) GetPlayerIcon() string {
+	var keys []string
+	for key := range mprisPlayerIcons {
+		keys = append(keys, key)
+	}
+	if key, found := p.matchKey(keys); found {
+		return mprisPlayerIcons[key]
+	}
+	return ""
+}
+
+// GetLabel returns the player icon, if any, followed by the playback icon.
+func (p *Properties) GetLabel() string {
+	icon := p.GetIcon()
+	if playerIcon := p.GetPlayerIcon(); len(playerIcon) > 0 {
+		return playerIcon + " " + icon
+	}
+	return icon
+}
+
+// getProperties returns the properties of the player owned by owner.
+func (l *Listener) getProperties(owner string) (p Properties, err error) {
+	if p, err = GetPlayerProperties(l.reader(owner)); err != nil {
+		return
+	}
+	p.Player = shortName(l.names[owner])
+	p.Identity = l.identities[owner].Identity
+	p.DesktopEntry = l.identities[owner].DesktopEntry
+	return
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
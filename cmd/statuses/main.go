@@ -25,6 +25,7 @@ var (
 	NewUnit = procmon.NewUnit
 	NewResource = procmon.NewResource
 	NewService = procmon.NewService
+	NewObject = procmon.NewObject
 	GetWaitGroup = procmon.GetWaitGroup
 )
 
@@ -77,7 +78,12 @@ func main() {
 		ticker.Stop()
 		cancel()
 	}()
+	// resources send their statuses to produced, teed into the statuses
+	// read by the service after their values are recorded
 	statuses := make(chan Status, 32)
+	produced := make(chan Status, 32)
+	values := NewValues()
+	go values.Tee(produced, statuses)
 	// initialize service/object/resources
 	service := NewService(
 		statuses,
@@ -97,10 +103,22 @@ func main() {
 			managed = append(managed, paths[k])
 		}
 	}
+	// files updates the file resources, the service ones only holding the
+	// statuses it publishes
+	files := NewObject(produced)
 	if len(managed) > 0 {
 		service.Object.AddFileResource(managed...)
-		service.Object.UpdateTimeBased(0)
+		files.AddFileResource(managed...)
+		files.UpdateTimeBased(0)
 	}
+	var pulseClient PulseClient
+	var mprisClient MprisClient
+	pulseClient = NewPulseClient()
+	service.Object.AddSimpleResource(pulseClient.Rc, nil)
+	mprisClient = NewMprisClient()
+	service.Object.AddSimpleResource(mprisClient.Rc, mprisClient.Updater)
+	pulseClient.Values = values
+	mprisClient.Values = values
 	// spin up workers
 	wg := GetWaitGroup()
 	// manage signals
@@ -116,6 +134,8 @@ func main() {
 						fmt.Println("Receive sighup.")
 					case os.Interrupt:
 						fmt.Println("Interrupted by user.")
+						// record the tracks playing
+						mprisClient.Close()
 						cancel()
 						os.Exit(1)
 					}
@@ -126,19 +146,20 @@ func main() {
 			}
 	}()
 	// launch service and update dbus properties
-	var pulseClient PulseClient
-	var mprisClient MprisClient
-	pulseClient = NewPulseClient()
-	service.Object.AddSimpleResource(pulseClient.Rc, nil)
-	mprisClient = NewMprisClient()
-	service.Object.AddSimpleResource(mprisClient.Rc, nil)
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		fmt.Println("Starting service on bus name", service.BusName(), "...")
 		service.Connect()
-		pulseClient.UpdateVolume(service.Object.Channel)
-		mprisClient.Connect(service.Conn, service.Object.Channel)
+		err := values.Export(service.Conn, service.Object.Resources)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to export values:", err)
+		}
+		pulseClient.UpdateVolume(produced)
+		if err := pulseClient.Export(service.Conn); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to export pulse control:", err)
+		}
+		mprisClient.Connect(service.Conn, produced)
 		service.Run(debug)
 	}()
 	// send statuses
@@ -153,14 +174,12 @@ func main() {
 					break loop
 				case <-ticker.C:
 					elapsed++
-					go service.Object.UpdateTimeBased(elapsed)
+					go files.UpdateTimeBased(elapsed)
 				case <-pulseClient.Channel:
-					go pulseClient.UpdateVolume(service.Object.Channel)
-				case message := <-mprisClient.Channel:
-					go mprisClient.UpdateMpris(message, service.Object.Channel)
+					go pulseClient.UpdateVolume(produced)
 				}
 			}
-		close(statuses)
+		close(produced)
 	}()
 	wg.Wait() // wait on the workers to finish
 	service.Object.Close()
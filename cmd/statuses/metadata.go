@@ -0,0 +1,129 @@
+package main
+
+import (
+	"strings"
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+// MprisMetadata holds the structured fields of the displayed player, exported
+// as properties of the mpris control object.
+type MprisMetadata struct {
+	BusName string
+	Player string
+	Identity string
+	DesktopEntry string
+	PlaybackStatus string
+	Artist string
+	Title string
+	Album string
+	TrackNumber int32
+	ArtUrl string
+	ArtPath string
+	Url string
+}
+
+func (p *Properties) GetMetadata() (m MprisMetadata) {
+	m.Player = p.Player
+	m.Identity = p.Identity
+	m.DesktopEntry = p.DesktopEntry
+	m.PlaybackStatus = string(p.PlaybackStatus)
+	m.Artist = p.GetArtist()
+	m.Title = p.GetTitle()
+	m.Album = p.Field(`album`)
+	m.ArtUrl = p.Field(`artUrl`)
+	m.Url = p.GetUrl()
+	if variant, found := p.Metadata[`xesam:trackNumber`]; found {
+		switch value := variant.Value().(type) {
+		case int32:
+			m.TrackNumber = value
+		case int64:
+			m.TrackNumber = int32(value)
+		case uint32:
+			m.TrackNumber = int32(value)
+		}
+	}
+	return
+}
+
+func (m MprisMetadata) values() map[string]interface{} {
+	return map[string]interface{}{
+		`BusName`: m.BusName,
+		`Player`: m.Player,
+		`Identity`: m.Identity,
+		`DesktopEntry`: m.DesktopEntry,
+		`PlaybackStatus`: m.PlaybackStatus,
+		`Artist`: m.Artist,
+		`Title`: m.Title,
+		`Album`: m.Album,
+		`TrackNumber`: m.TrackNumber,
+		`ArtUrl`: m.ArtUrl,
+		`ArtPath`: m.ArtPath,
+		`Url`: m.Url,
+	}
+}
+
+func buildMetadataSpec() (propsSpec prop.Map) {
+	propsSpec = make(prop.Map)
+	propsSpec[mprisControlIface] = make(map[string]*prop.Prop)
+	for key, value := range (MprisMetadata{}).values() {
+		propsSpec[mprisControlIface][key] = &prop.Prop{
+			Value: value,
+			Writable: false,
+			Emit: prop.EmitTrue,
+			Callback: nil,
+		}
+	}
+	propsSpec[mprisControlIface][`Privacy`] = &prop.Prop{
+		Value: false,
+		Writable: false,
+		Emit: prop.EmitTrue,
+		Callback: nil,
+	}
+	return
+}
+
+// getMetadata returns the metadata of the displayed player.
+func (l *Listener) getMetadata() (m MprisMetadata) {
+	if _, found := l.players[l.statusOwner]; !(found) {
+		return
+	}
+	m = l.ownerProperties.GetMetadata()
+	m.BusName = l.names[l.statusOwner]
+	if l.isPrivate() {
+		m.Artist, m.Title, m.Album, m.TrackNumber = "", "", "", 0
+		m.ArtUrl, m.Url = "", ""
+		return
+	}
+	m.ArtPath = l.artPath(m.ArtUrl)
+	return
+}
+
+// publishMetadata updates the properties that changed since last call.
+func (l *Listener) publishMetadata() {
+	if l.props == nil {
+		return
+	}
+	m := l.getMetadata()
+	if m == l.lastMetadata {
+		return
+	}
+	previous := l.lastMetadata.values()
+	for key, value := range m.values() {
+		if value != previous[key] {
+			l.props.SetMust(mprisControlIface, key, value)
+		}
+	}
+	l.lastMetadata = m
+}
+
+// shortName returns the bus name without the mpris prefix.
+func shortName(busName string) string {
+	return strings.TrimPrefix(busName, `org.mpris.MediaPlayer2.`)
+}
+
+func exportMetadata(conn *dbus.Conn) (*prop.Properties, error) {
+	return prop.Export(conn, dbus.ObjectPath(mprisControlPath), buildMetadataSpec())
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
@@ -7,7 +7,10 @@ import (
 	"sort"
 	"os"
 	"sync"
+	"time"
+	"flag"
 	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
 	mpris "github.com/Pauloo27/go-mpris"
 )
 
@@ -24,19 +27,56 @@ var mprisDefaults = map[string]string{
 	`iconNone`: ``,
 }
 
+var (
+	mprisPolicy = flag.String(
+		"mpris-policy",
+		"status",
+		"mpris player selection policy (status, recent)",
+	)
+	mprisTemplate = flag.String(
+		"mpris-template",
+		"",
+		"now playing template, like '{artist} - {title}|{url}'",
+	)
+	mprisPlayerTemplates = make(keyValues)
+	mprisWidth = flag.Int(
+		"mpris-width",
+		96,
+		"maximum width of now playing text, in terminal cells",
+	)
+	mprisMarquee = flag.Bool(
+		"mpris-marquee",
+		false,
+		"scroll now playing text when wider than maximum width",
+	)
+	mprisMarqueeGap = flag.String(
+		"mpris-marquee-gap",
+		"   ",
+		"text shown between the end and the start of scrolling text",
+	)
+)
+
 func init() {
 	defaultStatus.SetFormat("%s")
+	flag.Var(
+		mprisPlayerTemplates,
+		"mpris-player-template",
+		"now playing template for a player, like 'spotify={title}' (repeatable)",
+	)
 }
 
 // Properties
 type Properties struct {
+	Player string
+	Identity string
+	DesktopEntry string
 	PlaybackStatus mpris.PlaybackStatus
 	Metadata map[string]dbus.Variant
 	Icon string
 	NowPlaying string
 }
 
-func GetPlayerProperties(player *mpris.Player) (p Properties, err error) {
+func GetPlayerProperties(player playerReader) (p Properties, err error) {
 	playbackStatus, e := player.GetPlaybackStatus()
 	if e != nil {
 		err = e
@@ -66,13 +106,8 @@ func (p *Properties) GetIcon() (icon string) {
 	return
 }
 
-func truncate(text string, length int) (result string) {
-	if len(text) > length {
-		result = fmt.Sprintf("%s%s", text[:length], mprisDefaults[`truncate`])
-	} else {
-		result = text
-	}
-	return
+func truncate(text string, width int) (result string) {
+	return truncateWidth(text, width, mprisDefaults[`truncate`])
 }
 
 func (p *Properties) GetArtist() (artist string) {
@@ -156,6 +191,7 @@ type weightOwner struct {
 	weight int
 	owner string
 	status string
+	active time.Time
 }
 
 func (w *weightOwner) setOwner(owner string) int {
@@ -178,8 +214,11 @@ func (w *weightOwner) setStatus(playbackStatus mpris.PlaybackStatus) int {
 	return w.weight
 }
 
-func getWeightOwner(owner string, player *mpris.Player) (w weightOwner) {
+func getWeightOwner(owner string, player playerReader, active time.Time) (
+	w weightOwner,
+) {
 	w.setOwner(owner)
+	w.active = active
 	if playbackStatus, err := player.GetPlaybackStatus(); err == nil {
 		w.setStatus(playbackStatus)
 	} else {
@@ -188,6 +227,7 @@ func getWeightOwner(owner string, player *mpris.Player) (w weightOwner) {
 	return
 }
 
+// byWeight sorts by playback status, then by most recent activity
 type byWeight []weightOwner
 func (b byWeight) Len () int { return len(b) }
 func (b byWeight) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
@@ -196,10 +236,41 @@ func (b byWeight) Less(i, j int) bool {
 	case b[i].weight < b[j].weight:
 		return true
 	case b[i].weight == b[j].weight:
+		if !(b[i].active.Equal(b[j].active)) {
+			return b[i].active.Before(b[j].active)
+		}
 		return b[i].id < b[j].id
 	}
 	return false
 }
+
+// byActivity sorts by most recent activity, then by playback status
+type byActivity []weightOwner
+func (b byActivity) Len () int { return len(b) }
+func (b byActivity) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byActivity) Less(i, j int) bool {
+	if !(b[i].active.Equal(b[j].active)) {
+		return b[i].active.Before(b[j].active)
+	}
+	return byWeight(b).Less(i, j)
+}
+
+func isValidPolicy(policy string) bool {
+	switch policy {
+	case `status`, `recent`:
+		return true
+	}
+	return false
+}
+
+func sortOwners(owners []weightOwner, policy string) {
+	switch policy {
+	case `recent`:
+		sort.Sort(byActivity(owners))
+	default:
+		sort.Sort(byWeight(owners))
+	}
+}
 // weightOwner end
 
 // Listener
@@ -208,24 +279,123 @@ type Listener struct {
 	chanSignal chan *dbus.Signal
 	// ignoredPlayers []string
 	players map[string]*mpris.Player
+	names map[string]string
+	identities map[string]playerIdentity
+	activity map[string]time.Time
+	positions map[string]*trackPosition
 	lastStatus Status
+	lastProgress Status
+	lastPlayers Status
+	nowPlaying string
+	scroll int
 	statusOwner string
+	pinned string
+	privacy bool
+	lastPrivacy bool
+	triggers map[string]bool
 	ownerProperties Properties
+	lastMetadata MprisMetadata
+	props *prop.Properties
+	history *historyRecorder
+	art *artCache
+	notifier *notifier
+	artPaths map[string]string
+	artFailures map[string]time.Time
+	values *Values
 	connected bool
-	// TODO: try to isolate
-	// chanStatus chan Status
+	chanStatus chan Status
+	commands chan func()
+	quit chan struct{}
+	closing sync.Once
+	// started and done are closed when Run starts and returns
+	started chan struct{}
+	done chan struct{}
 }
 
 func NewListener() *Listener {
 	l := &Listener{}
 	l.chanSignal = make(chan *dbus.Signal, 16)
+	l.commands = make(chan func())
+	l.quit = make(chan struct{})
+	l.started = make(chan struct{})
+	l.done = make(chan struct{})
 	l.players = make(map[string]*mpris.Player)
+	l.names = make(map[string]string)
+	l.identities = make(map[string]playerIdentity)
+	l.activity = make(map[string]time.Time)
+	l.triggers = make(map[string]bool)
+	l.privacy = *privacyEnabled
+	l.positions = make(map[string]*trackPosition)
 	l.lastStatus = defaultStatus
+	l.lastProgress = defaultProgress
+	l.lastPlayers = defaultPlayers
+	if len(*historyPath) > 0 {
+		l.history = newHistoryRecorder(*historyPath, *historyMin)
+	}
+	l.artPaths = make(map[string]string)
+	l.artFailures = make(map[string]time.Time)
+	if *notifyEnabled {
+		l.notifier = newNotifier()
+	}
+	if *artEnabled {
+		if art, err := newArtCache(); err == nil {
+			l.art = art
+		} else {
+			fmt.Fprintln(os.Stderr, "album art cache disabled:", err)
+		}
+	}
 	return l
 }
 
+// Close stops the listener and, when running, waits for it to record the
+// tracks playing.
 func (l *Listener) Close() {
-	l.disconnect()
+	l.closing.Do(func() { close(l.quit) })
+	select {
+	case <-l.started:
+		<-l.done
+	default:
+	}
+}
+
+// Run owns the players state: signals, method calls and ticks are all
+// processed in order from this goroutine, until Close is called.
+func (l *Listener) Run() {
+	close(l.started)
+	defer close(l.done)
+	ticker := time.NewTicker(time.Second)
+	defer func() {
+		ticker.Stop()
+		l.disconnect()
+	}()
+	l.refreshStatus()
+	for {
+		select {
+		case <-l.quit:
+			return
+		case message := <-l.chanSignal:
+			l.handleSignal(message)
+		case command := <-l.commands:
+			command()
+		case <-ticker.C:
+			l.refreshMarquee()
+			l.refreshProgress()
+			l.flushNotification()
+		}
+	}
+}
+
+// do runs f from the goroutine running the listener and waits for it.
+func (l *Listener) do(f func()) {
+	done := make(chan struct{})
+	select {
+	case l.commands <- func() {
+		defer close(done)
+		f()
+	}:
+		<-done
+	case <-l.quit:
+	}
 }
 
 func (l *Listener) handlePropertiesChanged(message *dbus.Signal) bool {
@@ -233,30 +403,55 @@ func (l *Listener) handlePropertiesChanged(message *dbus.Signal) bool {
 	if busName != "org.mpris.MediaPlayer2.Player" {
 		return false
 	}
+	var flag, changed, trackChanged bool
 	if _, found := l.players[message.Sender]; found {
 		if properties, ok := message.Body[1].(map[string]dbus.Variant); ok {
-			for key, _ := range properties {
+			for key, variant := range properties {
 				switch key {
-				case `PlaybackStatus`, `Volume`, `Metadata`:
-					return true
+				case `PlaybackStatus`:
+					l.activity[message.Sender] = time.Now()
+					changed = true
+					flag = true
+					t, found := l.positions[message.Sender]
+					if status, ok := variant.Value().(string); ok && found {
+						t.setPlaying(
+							status == string(mpris.PlaybackPlaying),
+							time.Now(),
+						)
+					}
+				case `Metadata`:
+					l.activity[message.Sender] = time.Now()
+					changed = true
+					trackChanged = true
+					flag = true
+				case `Volume`:
+					flag = true
+				case `Rate`:
+					t, found := l.positions[message.Sender]
+					if rate, ok := variant.Value().(float64); ok && found && rate > 0 {
+						t.setRate(rate, time.Now())
+					}
 				}
 			}
 		}
 	}
-	return false
+	// the position is read once per track, then extrapolated
+	if trackChanged {
+		l.syncPosition(message.Sender)
+	}
+	if changed {
+		l.trackHistory(message.Sender)
+	}
+	return flag
 }
 
-// func (l *Listener) handleSeeked(message *dbus.Signal) bool{
-//   return false
-// }
-
 func (l *Listener) handleNameOwnerChanged(message *dbus.Signal) bool {
 	busName := fmt.Sprintf("%s", message.Body[0])
-	if !(l.isValidPlayer(busName)) {
-		return false
-	}
 	oldOwner := fmt.Sprintf("%s", message.Body[1])
 	newOwner := fmt.Sprintf("%s", message.Body[2])
+	if !(l.isValidPlayer(busName)) {
+		return l.handlePrivacyTrigger(busName, newOwner)
+	}
 	if len(newOwner) > 0 {
 		if len(oldOwner) > 0 {
 			return l.changePlayerOwner(busName, oldOwner, newOwner)
@@ -269,27 +464,37 @@ func (l *Listener) handleNameOwnerChanged(message *dbus.Signal) bool {
 	return false
 }
 
-func (l *Listener) HandleSignal(message *dbus.Signal, channel chan Status) {
+func (l *Listener) handleSignal(message *dbus.Signal) {
 	// TODO: suppress after debug
 	// fmt.Printf("debug: got signal: %v sender: %v\n", message.Name, message.Sender)
 	var flag bool
 	switch message.Name {
 	case "org.freedesktop.DBus.Properties.PropertiesChanged":
 		flag = l.handlePropertiesChanged(message)
-	// case "org.mpris.MediaPlayer2.Seeked":
-	//   flag = l.handleSeeked(message)
+	case "org.mpris.MediaPlayer2.Player.Seeked":
+		if l.handleSeeked(message) {
+			l.refreshProgress()
+		}
 	case "org.freedesktop.DBus.NameOwnerChanged":
 		flag = l.handleNameOwnerChanged(message)
 	}
 	if flag {
-		go l.RefreshStatus(channel)
+		l.refreshStatus()
 	}
 }
 
 func (l *Listener) connect(conn *dbus.Conn, channel chan Status) {
 	l.conn = conn
 	l.connected = true
-	l.getRunningPlayers(channel)
+	l.chanStatus = channel
+	props, err := exportMprisControl(l.conn, &MprisControl{l})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "export mpris control failed:", err)
+		os.Exit(1)
+	}
+	l.props = props
+	l.getRunningPlayers()
+	l.getRunningTriggers()
 	// Start listenning to some signals on various interfaces
 	fmt.Println("Connecting signals...")
 	var matchOptions = map[string]map[string]string{
@@ -297,10 +502,10 @@ func (l *Listener) connect(conn *dbus.Conn, channel chan Status) {
 			`opath`: `/org/mpris/MediaPlayer2`,
 			`iface`: `org.freedesktop.DBus.Properties`,
 		},
-		// `Seeked`: map[string]string{
-		//   `opath`: `/org/mpris/MediaPlayer2`,
-		//   `iface`: `org.mpris.MediaPlayer2`,
-		// },
+		`Seeked`: map[string]string{
+			`opath`: `/org/mpris/MediaPlayer2`,
+			`iface`: `org.mpris.MediaPlayer2.Player`,
+		},
 		`NameOwnerChanged`: map[string]string{
 			`opath`: `/org/freedesktop/DBus`,
 			`iface`: `org.freedesktop.DBus`,
@@ -321,6 +526,9 @@ func (l *Listener) connect(conn *dbus.Conn, channel chan Status) {
 
 func (l *Listener) disconnect() {
 	l.connected = false
+	if l.history != nil {
+		l.history.finishAll()
+	}
 	// Disconnect players if required with lib
 }
 
@@ -345,7 +553,7 @@ func (l *Listener) getNameOwner(name string) (owner string, err error) {
 	return
 }
 
-func (l *Listener) getRunningPlayers(channel chan Status) {
+func (l *Listener) getRunningPlayers() {
 	if names, err := mpris.List(l.conn); err == nil {
 		for _, name := range names {
 			if !(l.isValidPlayer(name)) {
@@ -361,13 +569,16 @@ func (l *Listener) getRunningPlayers(channel chan Status) {
 	}
 	// TODO: suppress after debug
 	// fmt.Printf("debug: initial players %+v\n", l.players)
-	go l.RefreshStatus(channel)
 }
 
 func (l *Listener) addPlayer(busName, owner string) (flag bool) {
 	// TODO: suppress after debug
 	// fmt.Printf("debug: adding player %s[%s]\n", busName, owner)
 	l.players[owner] = mpris.New(l.conn, busName)
+	l.names[owner] = busName
+	l.identities[owner] = getPlayerIdentity(l.reader(owner))
+	l.syncPosition(owner)
+	l.trackHistory(owner)
 	flag = true
 	return
 }
@@ -376,7 +587,15 @@ func (l *Listener) removePlayer(busName, owner string) (flag bool) {
 	if _, found := l.players[owner]; found {
 		// TODO: suppress after debug
 		// fmt.Printf("debug: removing player %s[%s]\n", busName, owner)
+		l.finishHistory(owner)
 		delete(l.players, owner)
+		delete(l.names, owner)
+		delete(l.identities, owner)
+		delete(l.activity, owner)
+		delete(l.positions, owner)
+		if owner == l.pinned {
+			l.pinned = ""
+		}
 		flag = true
 	}
 	return
@@ -393,14 +612,21 @@ func (l *Listener) changePlayerOwner(busName, oldOwner, newOwner string) (flag b
 }
 
 func (l *Listener) getStatusOwner() (statusOwner string) {
+	if _, found := l.players[l.pinned]; found {
+		l.statusOwner = l.pinned
+		return l.pinned
+	}
 	var owners []weightOwner
-	for owner, player := range l.players {
-		owners = append(owners, getWeightOwner(owner, player))
+	for owner := range l.players {
+		owners = append(
+			owners,
+			getWeightOwner(owner, l.reader(owner), l.activity[owner]),
+		)
 	}
 	// TODO: suppress after debug
 	// fmt.Printf("debug: owners %+v\n", owners)
 	if len(owners) > 0 {
-		sort.Sort(byWeight(owners))
+		sortOwners(owners, *mprisPolicy)
 		statusOwner = fmt.Sprintf("%s", owners[len(owners) - 1].owner)
 	}
 	l.statusOwner = statusOwner
@@ -409,20 +635,57 @@ func (l *Listener) getStatusOwner() (statusOwner string) {
 
 func (l *Listener) getPlayerStatus(owner string) (s Status, err error) {
 	s = defaultStatus
-	properties, e := GetPlayerProperties(l.players[owner])
+	properties, e := l.getProperties(owner)
 	if e != nil {
 		err = e
 		return
 	}
 	l.ownerProperties = properties
-	s.Label = l.ownerProperties.GetIcon()
-	if s.Label == mprisDefaults[`iconStopped`] {
+	if l.ownerProperties.GetIcon() == mprisDefaults[`iconStopped`] {
+		s.Label = l.ownerProperties.Icon
+		return
+	}
+	s.Label = l.ownerProperties.GetLabel()
+	var nowPlaying string
+	if t, found := templateFor(&l.ownerProperties); found {
+		nowPlaying = l.ownerProperties.Render(t)
+	} else {
+		nowPlaying = l.ownerProperties.GetNowPlaying()
+	}
+	if nowPlaying != l.nowPlaying {
+		l.nowPlaying = nowPlaying
+		l.scroll = 0
+	}
+	if l.isPrivate() {
+		s.Value = *privacyPlaceholder
 		return
 	}
-	s.Value = truncate(l.ownerProperties.GetNowPlaying(), 96)
+	s.Value = l.fitNowPlaying()
 	return
 }
 
+// isScrolling reports whether now playing text scrolls as a marquee.
+func (l *Listener) isScrolling() bool {
+	return !(l.isPrivate()) && *mprisMarquee && stringWidth(l.nowPlaying) > *mprisWidth
+}
+
+func (l *Listener) fitNowPlaying() string {
+	if l.isScrolling() {
+		return marquee(l.nowPlaying, *mprisMarqueeGap, *mprisWidth, l.scroll)
+	}
+	return truncate(l.nowPlaying, *mprisWidth)
+}
+
+// refreshMarquee scrolls now playing text by one character.
+func (l *Listener) refreshMarquee() {
+	if !(l.isScrolling()) || l.lastStatus.Value == "" {
+		return
+	}
+	l.scroll++
+	l.lastStatus.Value = l.fitNowPlaying()
+	l.chanStatus <- l.lastStatus
+}
+
 func (l *Listener) getStatus() (s Status) {
 	if len(l.getStatusOwner()) > 0 {
 		if status, err := l.getPlayerStatus(l.statusOwner); err == nil {
@@ -436,7 +699,7 @@ func (l *Listener) getStatus() (s Status) {
 	return
 }
 
-func (l *Listener) RefreshStatus(channel chan Status) {
+func (l *Listener) refreshStatus() {
 	var flag bool
 	s := l.getStatus()
 	if s.Label != l.lastStatus.Label {
@@ -451,8 +714,33 @@ func (l *Listener) RefreshStatus(channel chan Status) {
 	// fmt.Printf("debug: updated status: %+v\n", s)
 	// fmt.Printf("debug: last status: %+v\n", l.lastStatus)
 	if flag {
-		channel <- l.lastStatus
+		l.chanStatus <- l.lastStatus
+	}
+	l.publishPlayback()
+	l.publishPrivacy()
+	l.publishMetadata()
+	l.checkTrackChange()
+	l.refreshProgress()
+	l.refreshPlayers()
+}
+
+// publishPlayback publishes the playback status of the displayed player as
+// the MprisPlayback value: 0 when stopped or without player, 1 when paused
+// and 2 when playing.
+func (l *Listener) publishPlayback() {
+	if l.values == nil {
+		return
 	}
+	var playback float64
+	if len(l.statusOwner) > 0 {
+		switch l.ownerProperties.PlaybackStatus {
+		case mpris.PlaybackPlaying:
+			playback = 2
+		case mpris.PlaybackPaused:
+			playback = 1
+		}
+	}
+	l.values.Set(`MprisPlayback`, playback)
 }
 // Listener end
 
@@ -463,25 +751,44 @@ func GetMprisResource() *Resource {
 		s.SetFormat("%s")
 		s.SetLabel(mprisDefaults[`iconStopped`])
 		s.SetValue("")
+		s = rc.AddStatus(`MprisProgress`)
+		s.SetFormat("%s")
+		s.SetLabel(mprisDefaults[`iconStopped`])
+		s.SetValue("")
+		s = rc.AddStatus(`MprisPlayers`)
+		s.SetFormat("%s")
+		s.SetLabel("")
+		s.SetValue("")
 	return rc
 }
 
 type MprisClient struct {
 	client *Listener
-	Channel chan *dbus.Signal
 	Rc *Resource
+	Values *Values
 }
 
 func NewMprisClient() MprisClient {
+	if !(isValidPolicy(*mprisPolicy)) {
+		fmt.Fprintln(os.Stderr, "invalid mpris policy:", *mprisPolicy)
+		os.Exit(1)
+	}
+	if err := parseTemplates(); err != nil {
+		fmt.Fprintln(os.Stderr, "invalid mpris template:", err)
+		os.Exit(1)
+	}
+	if err := parseOverview(); err != nil {
+		fmt.Fprintln(os.Stderr, "invalid mpris players overview:", err)
+		os.Exit(1)
+	}
 	c := MprisClient{}
 	c.client = NewListener()
-	c.Channel = c.client.chanSignal
-	// c.client.connect(channel)
 	c.Rc = GetMprisResource()
 	return c
 }
 
 func (c *MprisClient) Connect(conn *dbus.Conn, chanStatus chan Status) {
+	c.client.values = c.Values
 	c.client.connect(conn, chanStatus)
 }
 
@@ -489,18 +796,9 @@ func (c *MprisClient) Close() {
 	c.client.Close()
 }
 
-func (c *MprisClient) UpdateMpris(message *dbus.Signal, chanStatus chan Status) {
-	c.client.HandleSignal(message, chanStatus)
-}
-
 func (c *MprisClient) Updater(chanStatus chan Status, wg sync.WaitGroup) {
 	defer wg.Done()
-	for {
-		select {
-		case message := <-c.Channel:
-			c.client.HandleSignal(message, chanStatus)
-		}
-	}
+	c.client.Run()
 }
 
 // vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
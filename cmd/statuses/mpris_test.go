@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	mpris "github.com/Pauloo27/go-mpris"
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+// startBus starts a private session bus and returns its address, skipping
+// the test when no dbus-daemon is available.
+func startBus(t *testing.T) string {
+	path, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+	cmd := exec.Command(path, "--session", "--nofork", "--print-address")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Skip("dbus-daemon failed to start:", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	address, err := bufio.NewReader(out).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(address)
+}
+
+func connectBus(t *testing.T, address string) *dbus.Conn {
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// stubPlayer owns an mpris bus name and exports the properties read by the
+// listener.
+type stubPlayer struct {
+	conn *dbus.Conn
+	props *prop.Properties
+}
+
+func newStubPlayer(t *testing.T, address, name string) *stubPlayer {
+	conn := connectBus(t, address)
+	metadata := map[string]dbus.Variant{
+		`mpris:trackid`: dbus.MakeVariant(dbus.ObjectPath(`/track/1`)),
+		`xesam:title`: dbus.MakeVariant(`Title`),
+		`xesam:artist`: dbus.MakeVariant([]string{`Artist`}),
+	}
+	spec := prop.Map{
+		mpris.BaseInterface: {
+			`Identity`: {Value: name, Emit: prop.EmitTrue},
+			`DesktopEntry`: {Value: name, Emit: prop.EmitTrue},
+		},
+		mpris.PlayerInterface: {
+			`PlaybackStatus`: {Value: `Playing`, Emit: prop.EmitTrue},
+			`Metadata`: {Value: metadata, Emit: prop.EmitTrue},
+			`Position`: {Value: int64(0), Emit: prop.EmitFalse},
+			`Rate`: {Value: 1.0, Emit: prop.EmitTrue},
+			`Volume`: {Value: 1.0, Emit: prop.EmitTrue},
+		},
+	}
+	props, err := prop.Export(conn, `/org/mpris/MediaPlayer2`, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err := conn.RequestName(
+		mpris.BaseInterface + "." + name,
+		dbus.NameFlagDoNotQueue,
+	)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatal("failed to own player name:", err)
+	}
+	return &stubPlayer{conn, props}
+}
+
+func (p *stubPlayer) setStatus(status string) {
+	p.props.SetMust(mpris.PlayerInterface, `PlaybackStatus`, status)
+}
+
+// startListener runs a listener connected to the bus, draining its statuses.
+func startListener(t *testing.T, address string) *Listener {
+	statuses := make(chan Status)
+	l := NewListener()
+	l.connect(connectBus(t, address), statuses)
+	go func() {
+		for range statuses {
+		}
+	}()
+	go l.Run()
+	t.Cleanup(l.Close)
+	return l
+}
+
+// waitFor polls cond, run on the listener goroutine, until it holds.
+func waitFor(t *testing.T, l *Listener, what string, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var ok bool
+		l.do(func() { ok = cond() })
+		if ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestListenerOrdering checks that the signals of a player are handled in
+// order while control methods are called concurrently, the last playback
+// status being the displayed one.
+func TestListenerOrdering(t *testing.T) {
+	address := startBus(t)
+	l := startListener(t, address)
+	c := MprisControl{l}
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				c.ListPlayers()
+				c.NextPlayer()
+				c.UnpinPlayer()
+			}
+		}()
+	}
+	p := newStubPlayer(t, address, `stub`)
+	waitFor(t, l, "player", func() bool { return len(l.players) == 1 })
+	for i := 0; i < 50; i++ {
+		p.setStatus(`Paused`)
+		p.setStatus(`Playing`)
+	}
+	p.setStatus(`Paused`)
+	paused := mprisDefaults[`iconPaused`]
+	waitFor(t, l, "paused status", func() bool {
+		return l.lastStatus.Label == paused
+	})
+	close(stop)
+	wg.Wait()
+	// no late signal overrides the last status
+	time.Sleep(100 * time.Millisecond)
+	l.do(func() {
+		if l.lastStatus.Label != paused {
+			t.Errorf("got label %q, want %q", l.lastStatus.Label, paused)
+		}
+	})
+	players, _ := c.ListPlayers()
+	if len(players) != 1 || players[0] != mpris.BaseInterface + ".stub" {
+		t.Errorf("got players %v", players)
+	}
+}
+
+// TestListenerRemovePlayer checks that a player leaving the bus is removed.
+func TestListenerRemovePlayer(t *testing.T) {
+	address := startBus(t)
+	l := startListener(t, address)
+	p := newStubPlayer(t, address, `stub`)
+	waitFor(t, l, "player", func() bool { return len(l.players) == 1 })
+	p.conn.Close()
+	waitFor(t, l, "player removal", func() bool {
+		return len(l.players) == 0 &&
+			l.lastStatus.Label == defaultStatus.Label
+	})
+}
+
+// hungProperties never replies to the properties requests.
+type hungProperties struct {
+	release chan struct{}
+}
+
+func (h hungProperties) Get(iface, name string) (
+	dbus.Variant,
+	*dbus.Error,
+) {
+	<-h.release
+	return dbus.Variant{}, dbus.MakeFailedError(errors.New("released"))
+}
+
+// TestListenerHungPlayer checks that a player that never replies does not
+// block the listener for longer than the player timeout.
+func TestListenerHungPlayer(t *testing.T) {
+	saved := *playerTimeout
+	*playerTimeout = 50 * time.Millisecond
+	defer func() { *playerTimeout = saved }()
+	address := startBus(t)
+	l := startListener(t, address)
+	conn := connectBus(t, address)
+	h := hungProperties{make(chan struct{})}
+	defer close(h.release)
+	if err := conn.Export(
+		h,
+		`/org/mpris/MediaPlayer2`,
+		`org.freedesktop.DBus.Properties`,
+	); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.RequestName(
+		mpris.BaseInterface + ".hung",
+		dbus.NameFlagDoNotQueue,
+	); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	waitFor(t, l, "hung player", func() bool { return len(l.players) == 1 })
+	if _, err := (MprisControl{l}).ListPlayers(); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 2 * time.Second {
+		t.Errorf("listener blocked for %v", elapsed)
+	}
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	mpris "github.com/Pauloo27/go-mpris"
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+const (
+	mprisControlIface = `com.github.canalguada.gostatuses.Mpris`
+	mprisControlPath = `/com/github/canalguada/gostatuses/Mpris`
+)
+
+var (
+	errUnknownPlayer = `com.github.canalguada.gostatuses.Mpris.UnknownPlayer`
+	errNoPlayer = `com.github.canalguada.gostatuses.Mpris.NoPlayer`
+)
+
+// lookupOwner returns the unique name of the player matching name, that can
+// be a unique name, a full bus name or the part following the mpris prefix.
+func (l *Listener) lookupOwner(name string) (owner string, found bool) {
+	if _, found = l.players[name]; found {
+		owner = name
+		return
+	}
+	for owner, busName := range l.names {
+		if busName == name || shortName(busName) == name {
+			return owner, true
+		}
+	}
+	return
+}
+
+// sortedOwners returns players unique names, ordered by bus name.
+func (l *Listener) sortedOwners() (owners []string) {
+	for owner := range l.players {
+		owners = append(owners, owner)
+	}
+	sort.Slice(owners, func(i, j int) bool {
+		return l.names[owners[i]] < l.names[owners[j]]
+	})
+	return
+}
+
+func (l *Listener) pin(owner string) {
+	l.pinned = owner
+	l.refreshStatus()
+}
+
+func (l *Listener) unpin() {
+	l.pinned = ""
+	l.refreshStatus()
+}
+
+// cyclePlayer pins the player found step positions away from the displayed
+// one and returns its bus name.
+func (l *Listener) cyclePlayer(step int) (busName string) {
+	owners := l.sortedOwners()
+	if len(owners) == 0 {
+		return
+	}
+	current := l.statusOwner
+	if len(l.pinned) > 0 {
+		current = l.pinned
+	}
+	index := -1
+	for i, owner := range owners {
+		if owner == current {
+			index = i
+			break
+		}
+	}
+	if index < 0 && step < 0 {
+		index = 0
+	}
+	index = (index + step + len(owners)) % len(owners)
+	l.pin(owners[index])
+	return l.names[owners[index]]
+}
+
+// displayedPlayer returns the player shown in the Mpris status, if any.
+func (l *Listener) displayedPlayer() *mpris.Player {
+	if len(l.pinned) > 0 {
+		return l.players[l.pinned]
+	}
+	return l.players[l.statusOwner]
+}
+
+// MprisControl methods are called from godbus goroutines and run on the
+// listener goroutine.
+type MprisControl struct {
+	l *Listener
+}
+
+func (c MprisControl) NextPlayer() (busName string, err *dbus.Error) {
+	c.l.do(func() { busName = c.l.cyclePlayer(1) })
+	return
+}
+
+func (c MprisControl) PreviousPlayer() (busName string, err *dbus.Error) {
+	c.l.do(func() { busName = c.l.cyclePlayer(-1) })
+	return
+}
+
+func (c MprisControl) PinPlayer(busName string) (err *dbus.Error) {
+	c.l.do(func() {
+		owner, found := c.l.lookupOwner(busName)
+		if !(found) {
+			err = dbus.NewError(
+				errUnknownPlayer,
+				[]interface{}{fmt.Sprintf("unknown player: %s", busName)},
+			)
+			return
+		}
+		c.l.pin(owner)
+	})
+	return
+}
+
+func (c MprisControl) UnpinPlayer() *dbus.Error {
+	c.l.do(c.l.unpin)
+	return nil
+}
+
+func (c MprisControl) ListPlayers() (result []string, err *dbus.Error) {
+	c.l.do(func() {
+		for _, owner := range c.l.sortedOwners() {
+			result = append(result, c.l.names[owner])
+		}
+	})
+	return
+}
+
+// control calls action on the displayed player, out of the listener
+// goroutine that must keep handling the player signals meanwhile.
+func (c MprisControl) control(action func(*mpris.Player) error) *dbus.Error {
+	var player *mpris.Player
+	c.l.do(func() { player = c.l.displayedPlayer() })
+	if player == nil {
+		return dbus.NewError(errNoPlayer, []interface{}{"no player"})
+	}
+	if err := action(player); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+func (c MprisControl) PlayPause() *dbus.Error {
+	return c.control((*mpris.Player).PlayPause)
+}
+
+func (c MprisControl) Next() *dbus.Error {
+	return c.control((*mpris.Player).Next)
+}
+
+func (c MprisControl) Previous() *dbus.Error {
+	return c.control((*mpris.Player).Previous)
+}
+
+func (c MprisControl) Stop() *dbus.Error {
+	return c.control((*mpris.Player).Stop)
+}
+
+func exportMprisControl(conn *dbus.Conn, c *MprisControl) (
+	props *prop.Properties,
+	err error,
+) {
+	path := dbus.ObjectPath(mprisControlPath)
+	if err = conn.Export(c, path, mprisControlIface); err != nil {
+		return
+	}
+	if props, err = exportMetadata(conn); err != nil {
+		return
+	}
+	n := &introspect.Node{
+		Name: mprisControlPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       mprisControlIface,
+				Methods:    introspect.Methods(c),
+				Properties: props.Introspection(mprisControlIface),
+			},
+		},
+	}
+	err = conn.Export(
+		introspect.NewIntrospectable(n),
+		path,
+		"org.freedesktop.DBus.Introspectable",
+	)
+	return
+}
+// MprisControl end
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"time"
+	"github.com/godbus/dbus/v5"
+	mpris "github.com/Pauloo27/go-mpris"
+)
+
+var (
+	notifyEnabled = flag.Bool(
+		"mpris-notify",
+		false,
+		"send a desktop notification on track change",
+	)
+	notifyInterval = flag.Duration(
+		"mpris-notify-interval",
+		5 * time.Second,
+		"minimum interval between notifications",
+	)
+	notifyReplace = flag.Bool(
+		"mpris-notify-replace",
+		true,
+		"replace the previous notification",
+	)
+	notifyTimeout = flag.Int(
+		"mpris-notify-timeout",
+		-1,
+		"notification timeout in milliseconds (-1: server default)",
+	)
+	notifyIgnored stringList
+)
+
+func init() {
+	flag.Var(
+		&notifyIgnored,
+		"mpris-notify-ignore",
+		"player that never sends notifications (repeatable)",
+	)
+}
+
+type notification struct {
+	summary string
+	body string
+	icon string
+}
+
+// notifier sends track-change notifications, at most once per interval,
+// the last pending one being sent when the interval elapses.
+type notifier struct {
+	interval time.Duration
+	replace bool
+	timeout int32
+	last time.Time
+	id uint32
+	trackKey string
+	pending *notification
+}
+
+func newNotifier() *notifier {
+	return &notifier{
+		interval: *notifyInterval,
+		replace: *notifyReplace,
+		timeout: int32(*notifyTimeout),
+	}
+}
+
+func isNotifyIgnored(p *Properties) bool {
+	for _, key := range notifyIgnored {
+		if p.Matches(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *Listener) getNotification(p *Properties) (n *notification) {
+	m := p.GetMetadata()
+	n = &notification{summary: m.Title}
+	if len(n.summary) == 0 {
+		n.summary = l.nowPlaying
+	}
+	var body []string
+	for _, value := range []string{m.Artist, m.Album} {
+		if len(value) > 0 {
+			body = append(body, value)
+		}
+	}
+	n.body = strings.Join(body, " — ")
+	n.icon = l.artPath(m.ArtUrl)
+	if len(n.icon) == 0 && strings.HasPrefix(m.ArtUrl, `file://`) {
+		n.icon = m.ArtUrl
+	}
+	return
+}
+
+// checkTrackChange queues a notification when the displayed player starts
+// playing a new track.
+func (l *Listener) checkTrackChange() {
+	if l.notifier == nil {
+		return
+	}
+	if _, found := l.players[l.statusOwner]; !(found) {
+		return
+	}
+	p := &l.ownerProperties
+	if p.PlaybackStatus != mpris.PlaybackPlaying {
+		return
+	}
+	key := l.statusOwner + "\x00" + trackKey(p)
+	if key == l.notifier.trackKey {
+		return
+	}
+	l.notifier.trackKey = key
+	if l.isPrivate() || isNotifyIgnored(p) || len(l.nowPlaying) == 0 {
+		return
+	}
+	l.notifier.pending = l.getNotification(p)
+	l.flushNotification()
+}
+
+// flushNotification sends the pending notification, if the interval since
+// the last one elapsed.
+func (l *Listener) flushNotification() {
+	n := l.notifier
+	if n == nil || n.pending == nil || time.Since(n.last) < n.interval {
+		return
+	}
+	if l.isPrivate() {
+		n.pending = nil
+		return
+	}
+	pending := n.pending
+	n.pending = nil
+	n.last = time.Now()
+	var replacesId uint32
+	if n.replace {
+		replacesId = n.id
+	}
+	hints := map[string]dbus.Variant{}
+	if strings.HasPrefix(pending.icon, "/") {
+		hints[`image-path`] = dbus.MakeVariant(pending.icon)
+	}
+	obj := l.conn.Object(
+		`org.freedesktop.Notifications`,
+		`/org/freedesktop/Notifications`,
+	)
+	go func() {
+		var id uint32
+		err := obj.Call(
+			`org.freedesktop.Notifications.Notify`,
+			0,
+			`gostatuses`,
+			replacesId,
+			pending.icon,
+			pending.summary,
+			pending.body,
+			[]string{},
+			hints,
+			n.timeout,
+		).Store(&id)
+		if err != nil {
+			return
+		}
+		l.do(func() { n.id = id })
+	}()
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
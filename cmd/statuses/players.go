@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"flag"
+	"strings"
+)
+
+var defaultPlayers Status = Status{
+	Content: Content{Label: "", Value: ""},
+	Tag: `MprisPlayers`,
+}
+
+var (
+	playersOrder = flag.String(
+		"mpris-players-order",
+		"status",
+		"mpris players overview order (status, recent, name)",
+	)
+	playersMax = flag.Int(
+		"mpris-players-max",
+		4,
+		"maximum number of players in overview",
+	)
+	playersWidth = flag.Int(
+		"mpris-players-width",
+		24,
+		"maximum width of each player in overview, in terminal cells",
+	)
+	playersSeparator = flag.String(
+		"mpris-players-separator",
+		" | ",
+		"separator between players in overview",
+	)
+	playersTemplate = flag.String(
+		"mpris-players-template",
+		"{player}",
+		"template of each player in overview",
+	)
+	overviewTemplate nowPlayingTemplate
+)
+
+func init() {
+	defaultPlayers.SetFormat("%s")
+}
+
+func isValidOrder(order string) bool {
+	return order == `name` || isValidPolicy(order)
+}
+
+// orderedOwners returns the unique names of the players, the first one being
+// the best candidate according to order.
+func (l *Listener) orderedOwners(order string) (result []string) {
+	if order == `name` {
+		return l.sortedOwners()
+	}
+	var owners []weightOwner
+	for owner := range l.players {
+		owners = append(
+			owners,
+			getWeightOwner(owner, l.reader(owner), l.activity[owner]),
+		)
+	}
+	sortOwners(owners, order)
+	for i := len(owners) - 1; i >= 0; i-- {
+		result = append(result, owners[i].owner)
+	}
+	return
+}
+
+func (l *Listener) getPlayerEntry(owner string) (entry string, err error) {
+	properties, err := l.getProperties(owner)
+	if err != nil {
+		return
+	}
+	var text string
+	if !(l.isPrivate()) {
+		text = properties.Render(overviewTemplate)
+	}
+	if len(text) == 0 {
+		text = properties.Player
+	}
+	entry = properties.GetLabel() + " " + truncate(text, *playersWidth)
+	return
+}
+
+func (l *Listener) getPlayers() (s Status) {
+	s = defaultPlayers
+	var entries []string
+	for _, owner := range l.orderedOwners(*playersOrder) {
+		if *playersMax > 0 && len(entries) >= *playersMax {
+			break
+		}
+		if entry, err := l.getPlayerEntry(owner); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+	s.Value = strings.Join(entries, *playersSeparator)
+	return
+}
+
+// refreshPlayers sends the overview of all known players.
+func (l *Listener) refreshPlayers() {
+	s := l.getPlayers()
+	if s.Value != l.lastPlayers.Value {
+		l.lastPlayers = s
+		l.chanStatus <- l.lastPlayers
+	}
+}
+
+// parseOverview checks the overview flags.
+func parseOverview() (err error) {
+	if !(isValidOrder(*playersOrder)) {
+		return fmt.Errorf("invalid order: %s", *playersOrder)
+	}
+	overviewTemplate, err = parseTemplate(*playersTemplate)
+	return
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
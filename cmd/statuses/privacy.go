@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"github.com/godbus/dbus/v5"
+)
+
+var (
+	privacyEnabled = flag.Bool(
+		"mpris-privacy",
+		false,
+		"hide media titles",
+	)
+	privacyPlaceholder = flag.String(
+		"mpris-privacy-placeholder",
+		"",
+		"text shown instead of media titles in privacy mode",
+	)
+	privacyTriggers stringList
+)
+
+func init() {
+	flag.Var(
+		&privacyTriggers,
+		"mpris-privacy-trigger",
+		"bus name prefix of an application turning privacy mode on while " +
+			"running, like 'com.obsproject.Studio' (repeatable)",
+	)
+}
+
+func isPrivacyTrigger(busName string) bool {
+	for _, prefix := range privacyTriggers {
+		if busName == prefix || strings.HasPrefix(busName, prefix + ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// isPrivate reports whether privacy mode is on, either manually or because
+// some trigger application is running.
+func (l *Listener) isPrivate() bool {
+	return l.privacy || len(l.triggers) > 0
+}
+
+// handlePrivacyTrigger follows trigger applications on the bus.
+func (l *Listener) handlePrivacyTrigger(busName, newOwner string) bool {
+	if !(isPrivacyTrigger(busName)) {
+		return false
+	}
+	private := l.isPrivate()
+	if len(newOwner) > 0 {
+		l.triggers[busName] = true
+	} else {
+		delete(l.triggers, busName)
+	}
+	return private != l.isPrivate()
+}
+
+func (l *Listener) getRunningTriggers() {
+	if len(privacyTriggers) == 0 {
+		return
+	}
+	var names []string
+	err := l.conn.BusObject().Call("org.freedesktop.DBus.ListNames", 0).Store(&names)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to get list of owned names:", err)
+		return
+	}
+	for _, name := range names {
+		if isPrivacyTrigger(name) {
+			l.triggers[name] = true
+		}
+	}
+}
+
+func (l *Listener) setPrivacy(enabled bool) {
+	l.privacy = enabled
+	l.refreshStatus()
+}
+
+func (l *Listener) publishPrivacy() {
+	if l.props == nil {
+		return
+	}
+	if private := l.isPrivate(); private != l.lastPrivacy {
+		l.props.SetMust(mprisControlIface, `Privacy`, private)
+		l.lastPrivacy = private
+	}
+}
+
+func (c MprisControl) SetPrivacy(enabled bool) *dbus.Error {
+	c.l.do(func() { c.l.setPrivacy(enabled) })
+	return nil
+}
+
+// TogglePrivacy switches manual privacy mode and returns whether privacy mode
+// is on.
+func (c MprisControl) TogglePrivacy() (private bool, err *dbus.Error) {
+	c.l.do(func() {
+		c.l.setPrivacy(!(c.l.privacy))
+		private = c.l.isPrivate()
+	})
+	return
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
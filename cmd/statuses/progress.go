@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"time"
+	"github.com/godbus/dbus/v5"
+	mpris "github.com/Pauloo27/go-mpris"
+)
+
+var defaultProgress Status = Status{
+	Content: Content{Label: mprisDefaults[`iconStopped`], Value: ""},
+	Tag: `MprisProgress`,
+}
+
+func init() {
+	defaultProgress.SetFormat("%s")
+}
+
+// trackPosition extrapolates the playback position from the last known
+// position, the time it was known and the playback rate.
+type trackPosition struct {
+	length time.Duration
+	offset time.Duration
+	anchor time.Time
+	rate float64
+	playing bool
+}
+
+func newTrackPosition() *trackPosition {
+	return &trackPosition{rate: 1.0, anchor: time.Now()}
+}
+
+func (t *trackPosition) at(now time.Time) (position time.Duration) {
+	position = t.offset
+	if t.playing {
+		position += time.Duration(float64(now.Sub(t.anchor)) * t.rate)
+	}
+	if t.length > 0 && position > t.length {
+		position = t.length
+	}
+	if position < 0 {
+		position = 0
+	}
+	return
+}
+
+func (t *trackPosition) seek(position time.Duration, now time.Time) {
+	t.offset = position
+	t.anchor = now
+}
+
+func (t *trackPosition) setRate(rate float64, now time.Time) {
+	t.seek(t.at(now), now)
+	t.rate = rate
+}
+
+func (t *trackPosition) setPlaying(playing bool, now time.Time) {
+	t.seek(t.at(now), now)
+	t.playing = playing
+}
+
+func (t *trackPosition) setLength(metadata map[string]dbus.Variant) {
+	t.length = 0
+	if variant, found := metadata[`mpris:length`]; found {
+		switch value := variant.Value().(type) {
+		case int64:
+			t.length = time.Duration(value) * time.Microsecond
+		case uint64:
+			t.length = time.Duration(value) * time.Microsecond
+		}
+	}
+}
+
+// sync reads the position once from the player, when it appears or its
+// track changes.
+func (t *trackPosition) sync(player playerReader) {
+	now := time.Now()
+	if metadata, err := player.GetMetadata(); err == nil {
+		t.setLength(metadata)
+	}
+	if rate, err := player.GetRate(); err == nil && rate > 0 {
+		t.rate = rate
+	}
+	if playbackStatus, err := player.GetPlaybackStatus(); err == nil {
+		t.playing = playbackStatus == mpris.PlaybackPlaying
+	}
+	t.seek(0, now)
+	if variant, err := player.GetPlayerProperty(`Position`); err == nil {
+		if value, ok := variant.Value().(int64); ok {
+			t.seek(time.Duration(value) * time.Microsecond, now)
+		}
+	}
+}
+
+func formatDuration(d time.Duration) string {
+	seconds := int(d.Round(time.Second) / time.Second)
+	if seconds >= 3600 {
+		return fmt.Sprintf(
+			"%d:%02d:%02d",
+			seconds / 3600,
+			seconds % 3600 / 60,
+			seconds % 60,
+		)
+	}
+	return fmt.Sprintf("%d:%02d", seconds / 60, seconds % 60)
+}
+
+// progress returns elapsed/total time and percent, when length is known.
+func (t *trackPosition) progress(now time.Time) (text string, percent int) {
+	position := t.at(now)
+	text = formatDuration(position)
+	if t.length > 0 {
+		percent = int(position * 100 / t.length)
+		text = fmt.Sprintf(
+			"%s/%s %d%%",
+			text,
+			formatDuration(t.length),
+			percent,
+		)
+	}
+	return
+}
+
+func (l *Listener) syncPosition(owner string) {
+	if _, found := l.players[owner]; !(found) {
+		return
+	}
+	if _, found := l.positions[owner]; !(found) {
+		l.positions[owner] = newTrackPosition()
+	}
+	l.positions[owner].sync(l.reader(owner))
+}
+
+func (l *Listener) handleSeeked(message *dbus.Signal) bool {
+	if t, found := l.positions[message.Sender]; found && len(message.Body) > 0 {
+		if value, ok := message.Body[0].(int64); ok {
+			t.seek(time.Duration(value) * time.Microsecond, time.Now())
+			return true
+		}
+	}
+	return false
+}
+
+func (l *Listener) getProgress() (s Status, percent int) {
+	s = defaultProgress
+	t, found := l.positions[l.statusOwner]
+	if !(found) || len(l.statusOwner) == 0 {
+		return
+	}
+	s.Label = l.lastStatus.Label
+	if s.Label == mprisDefaults[`iconStopped`] {
+		return
+	}
+	s.Value, percent = t.progress(time.Now())
+	return
+}
+
+// refreshProgress sends the extrapolated progress of the displayed player,
+// without querying it, and publishes its percent as the MprisProgress
+// value.
+func (l *Listener) refreshProgress() {
+	s, percent := l.getProgress()
+	if l.values != nil {
+		l.values.Set(`MprisProgress`, float64(percent))
+	}
+	if s.Label != l.lastProgress.Label || s.Value != l.lastProgress.Value {
+		l.lastProgress = s
+		l.chanStatus <- l.lastProgress
+	}
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
@@ -1,11 +1,25 @@
 package main
 
 import (
+	"flag"
 	"sync"
 	"math"
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
 	"github.com/pr11t/pulseaudio"
 )
 
+const (
+	pulseControlIface = `com.github.canalguada.gostatuses.Pulse`
+	pulseControlPath = `/com/github/canalguada/gostatuses/Pulse`
+)
+
+var volumeMax = flag.Int(
+	"volume-max",
+	100,
+	"maximum volume in percent that D-Bus methods may raise the volume to",
+)
+
 func GetPulseResource() *Resource {
 	rc := NewResource("pulse", "pulse")
 		rc.SetData(`mute`, 0)
@@ -37,6 +51,7 @@ type PulseClient struct {
 	client *pulseaudio.Client
 	Channel <-chan struct{}
 	Rc *Resource
+	Values *Values
 }
 
 func NewPulseClient() PulseClient {
@@ -80,6 +95,9 @@ func (c *PulseClient) UpdateVolume(chanStatus chan Status) (err error){
 		} else {
 			c.Rc.SetData(`mute`, 0)
 		}
+		if c.Values != nil {
+			c.Values.Set(`VolumeMute`, float64(c.Rc.GetData(`mute`)))
+		}
 		c.Rc.SetData(`volume`, value)
 	}
 	return
@@ -96,4 +114,94 @@ func (c *PulseClient) Updater(chanStatus chan Status, wg sync.WaitGroup) {
 	}
 }
 
+// PulseControl methods change the volume of the default sink.
+type PulseControl struct {
+	c *PulseClient
+}
+
+// currentVolume returns the volume in percents.
+func (p PulseControl) currentVolume() (volume int32, err *dbus.Error) {
+	value, e := p.c.client.Volume()
+	if e != nil {
+		return 0, dbus.MakeFailedError(e)
+	}
+	return int32(math.Round(float64(value * 100.0))), nil
+}
+
+// setVolume changes the volume from current, without raising it above the
+// maximum volume. A volume already above it may still be lowered.
+func (p PulseControl) setVolume(current, volume int32) (
+	result int32,
+	err *dbus.Error,
+) {
+	max := int32(*volumeMax)
+	switch {
+	case volume < 0:
+		volume = 0
+	case volume > max && volume > current:
+		volume = current
+		if current < max {
+			volume = max
+		}
+	}
+	if e := p.c.client.SetVolume(float32(volume) / 100.0); e != nil {
+		return 0, dbus.MakeFailedError(e)
+	}
+	return volume, nil
+}
+
+func (p PulseControl) SetVolume(volume int32) (result int32, err *dbus.Error) {
+	current, err := p.currentVolume()
+	if err != nil {
+		return
+	}
+	return p.setVolume(current, volume)
+}
+
+// ChangeVolume adds delta percents to the volume and returns the new one.
+func (p PulseControl) ChangeVolume(delta int32) (
+	result int32,
+	err *dbus.Error,
+) {
+	current, err := p.currentVolume()
+	if err != nil {
+		return
+	}
+	return p.setVolume(current, current + delta)
+}
+
+func (p PulseControl) ToggleMute() (mute bool, err *dbus.Error) {
+	mute, e := p.c.client.ToggleMute()
+	if e != nil {
+		return false, dbus.MakeFailedError(e)
+	}
+	return mute, nil
+}
+
+// Export exports the PulseControl methods on conn.
+func (c *PulseClient) Export(conn *dbus.Conn) (err error) {
+	p := PulseControl{c}
+	path := dbus.ObjectPath(pulseControlPath)
+	if err = conn.Export(p, path, pulseControlIface); err != nil {
+		return
+	}
+	n := &introspect.Node{
+		Name: pulseControlPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			{
+				Name:    pulseControlIface,
+				Methods: introspect.Methods(p),
+			},
+		},
+	}
+	err = conn.Export(
+		introspect.NewIntrospectable(n),
+		path,
+		"org.freedesktop.DBus.Introspectable",
+	)
+	return
+}
+// PulseControl end
+
 // vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
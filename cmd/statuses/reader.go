@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+	"github.com/godbus/dbus/v5"
+	mpris "github.com/Pauloo27/go-mpris"
+)
+
+var playerTimeout = flag.Duration(
+	"mpris-timeout",
+	500 * time.Millisecond,
+	"maximum time to wait for a player to reply",
+)
+
+// playerReader reads the properties of a player from the listener
+// goroutine: its calls time out, so that a hung player cannot block the
+// other ones.
+type playerReader struct {
+	conn *dbus.Conn
+	busName string
+}
+
+func (r playerReader) GetProperty(iface, name string) (
+	variant dbus.Variant,
+	err error,
+) {
+	ctx, cancel := context.WithTimeout(context.Background(), *playerTimeout)
+	defer cancel()
+	err = r.conn.Object(r.busName, `/org/mpris/MediaPlayer2`).CallWithContext(
+		ctx,
+		`org.freedesktop.DBus.Properties.Get`,
+		0,
+		iface,
+		name,
+	).Store(&variant)
+	return
+}
+
+func (r playerReader) GetPlayerProperty(name string) (dbus.Variant, error) {
+	return r.GetProperty(mpris.PlayerInterface, name)
+}
+
+func (r playerReader) GetPlaybackStatus() (
+	playbackStatus mpris.PlaybackStatus,
+	err error,
+) {
+	variant, err := r.GetPlayerProperty(`PlaybackStatus`)
+	if err != nil {
+		return
+	}
+	value, ok := variant.Value().(string)
+	if !(ok) {
+		err = fmt.Errorf("invalid playback status: %v", variant)
+		return
+	}
+	return mpris.PlaybackStatus(value), nil
+}
+
+func (r playerReader) GetMetadata() (
+	metadata map[string]dbus.Variant,
+	err error,
+) {
+	variant, err := r.GetPlayerProperty(`Metadata`)
+	if err != nil {
+		return
+	}
+	metadata, ok := variant.Value().(map[string]dbus.Variant)
+	if !(ok) {
+		err = fmt.Errorf("invalid metadata: %v", variant)
+	}
+	return
+}
+
+func (r playerReader) GetRate() (rate float64, err error) {
+	variant, err := r.GetPlayerProperty(`Rate`)
+	if err != nil {
+		return
+	}
+	rate, ok := variant.Value().(float64)
+	if !(ok) {
+		err = fmt.Errorf("invalid rate: %v", variant)
+	}
+	return
+}
+
+// reader returns the playerReader of the player owned by owner.
+func (l *Listener) reader(owner string) playerReader {
+	return playerReader{l.conn, l.names[owner]}
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"github.com/godbus/dbus/v5"
+)
+
+// Templates are made of literal text and {field} placeholders, where field
+// is either an alias (artist, title, album, ...) or any metadata key, like
+// {xesam:genre} or {vlc:nowplaying}. Alternatives are separated by '|': the
+// first one whose fields are all set is used. Text enclosed in '[' and ']'
+// is only rendered when its own fields are set, and may hold alternatives
+// too. Use '\' to escape special characters.
+//
+// Example: {artist} - {title}[ ({album})]|{title}|{url}
+
+var metadataAliases = map[string]string{
+	`albumArtist`: `xesam:albumArtist`,
+	`title`: `xesam:title`,
+	`album`: `xesam:album`,
+	`url`: `xesam:url`,
+	`comment`: `xesam:comment`,
+	`genre`: `xesam:genre`,
+	`track`: `xesam:trackNumber`,
+	`disc`: `xesam:discNumber`,
+	`length`: `mpris:length`,
+	`artUrl`: `mpris:artUrl`,
+	`trackid`: `mpris:trackid`,
+}
+
+type templateNode struct {
+	text string
+	field string
+	group [][]templateNode
+}
+
+type nowPlayingTemplate [][]templateNode
+
+func parseTemplate(s string) (t nowPlayingTemplate, err error) {
+	alternatives, pos, err := parseAlternatives([]rune(s), 0, false)
+	if err == nil && pos < len([]rune(s)) {
+		err = fmt.Errorf("unexpected ']' at %d", pos)
+	}
+	t = nowPlayingTemplate(alternatives)
+	return
+}
+
+func parseAlternatives(runes []rune, pos int, inGroup bool) (
+	alternatives [][]templateNode,
+	next int,
+	err error,
+) {
+	var nodes []templateNode
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			nodes = append(nodes, templateNode{text: text.String()})
+			text.Reset()
+		}
+	}
+	for pos < len(runes) {
+		r := runes[pos]
+		switch r {
+		case '\\':
+			pos++
+			if pos < len(runes) {
+				text.WriteRune(runes[pos])
+			}
+		case '|':
+			flush()
+			alternatives = append(alternatives, nodes)
+			nodes = nil
+		case '{':
+			end := pos + 1
+			for end < len(runes) && runes[end] != '}' {
+				end++
+			}
+			if end == len(runes) {
+				err = fmt.Errorf("unclosed '{' at %d", pos)
+				return
+			}
+			flush()
+			nodes = append(nodes, templateNode{field: string(runes[pos+1:end])})
+			pos = end
+		case '[':
+			flush()
+			group, end, e := parseAlternatives(runes, pos + 1, true)
+			if e != nil {
+				err = e
+				return
+			}
+			if end >= len(runes) {
+				err = fmt.Errorf("unclosed '[' at %d", pos)
+				return
+			}
+			nodes = append(nodes, templateNode{group: group})
+			pos = end
+		case ']':
+			if !(inGroup) {
+				err = fmt.Errorf("unexpected ']' at %d", pos)
+				return
+			}
+			flush()
+			alternatives = append(alternatives, nodes)
+			next = pos
+			return
+		default:
+			text.WriteRune(r)
+		}
+		pos++
+	}
+	flush()
+	alternatives = append(alternatives, nodes)
+	next = pos
+	return
+}
+
+func renderNodes(nodes []templateNode, field func(string) string) (
+	result string,
+	ok bool,
+) {
+	var b strings.Builder
+	for _, node := range nodes {
+		switch {
+		case len(node.field) > 0:
+			value := field(node.field)
+			if len(value) == 0 {
+				return
+			}
+			b.WriteString(value)
+		case node.group != nil:
+			b.WriteString(renderAlternatives(node.group, field))
+		default:
+			b.WriteString(node.text)
+		}
+	}
+	return b.String(), true
+}
+
+func renderAlternatives(
+	alternatives [][]templateNode,
+	field func(string) string,
+) string {
+	for _, nodes := range alternatives {
+		if result, ok := renderNodes(nodes, field); ok && len(result) > 0 {
+			return result
+		}
+	}
+	return ""
+}
+
+func (t nowPlayingTemplate) Render(field func(string) string) string {
+	return renderAlternatives(t, field)
+}
+
+func formatVariant(variant dbus.Variant, key string) string {
+	switch value := variant.Value().(type) {
+	case string:
+		return value
+	case []string:
+		return strings.Join(value, ", ")
+	case dbus.ObjectPath:
+		return string(value)
+	case int64:
+		if key == `mpris:length` {
+			return formatDuration(time.Duration(value) * time.Microsecond)
+		}
+		return fmt.Sprint(value)
+	case uint64:
+		if key == `mpris:length` {
+			return formatDuration(time.Duration(value) * time.Microsecond)
+		}
+		return fmt.Sprint(value)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// Field returns the value of a template field, or an empty string.
+func (p *Properties) Field(name string) string {
+	switch name {
+	case `artist`:
+		return p.GetArtist()
+	case `player`:
+		return p.Player
+	case `identity`:
+		return p.Identity
+	case `desktopEntry`:
+		return p.DesktopEntry
+	case `status`:
+		return string(p.PlaybackStatus)
+	}
+	key := name
+	if alias, found := metadataAliases[name]; found {
+		key = alias
+	}
+	if variant, found := p.Metadata[key]; found {
+		return formatVariant(variant, key)
+	}
+	return ""
+}
+
+func (p *Properties) Render(t nowPlayingTemplate) string {
+	p.NowPlaying = t.Render(p.Field)
+	return p.NowPlaying
+}
+
+// templateFor returns the template configured for the player, if any.
+func templateFor(p *Properties) (t nowPlayingTemplate, found bool) {
+	var keys []string
+	for key := range playerTemplates {
+		keys = append(keys, key)
+	}
+	if key, ok := p.matchKey(keys); ok {
+		return playerTemplates[key], true
+	}
+	if defaultTemplate != nil {
+		return defaultTemplate, true
+	}
+	return
+}
+
+var (
+	defaultTemplate nowPlayingTemplate
+	playerTemplates = make(map[string]nowPlayingTemplate)
+)
+
+// parseTemplates parses the templates given on the command line.
+func parseTemplates() (err error) {
+	if len(*mprisTemplate) > 0 {
+		if defaultTemplate, err = parseTemplate(*mprisTemplate); err != nil {
+			return
+		}
+	}
+	for player, s := range mprisPlayerTemplates {
+		if playerTemplates[player], err = parseTemplate(s); err != nil {
+			return fmt.Errorf("%s: %v", player, err)
+		}
+	}
+	return
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+	"github.com/godbus/dbus/v5"
+)
+
+func TestParseTemplateErrors(t *testing.T) {
+	for _, s := range []string{`[a`, `a]`, `{a`, `x[{a}`, `[a]]`, `|]`} {
+		if _, err := parseTemplate(s); err == nil {
+			t.Errorf("parseTemplate(%q): expected an error", s)
+		}
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	p := &Properties{
+		Player: `spotify`,
+		Metadata: map[string]dbus.Variant{
+			`xesam:title`: dbus.MakeVariant(`T`),
+			`xesam:artist`: dbus.MakeVariant([]string{`A`, `B`}),
+			`xesam:url`: dbus.MakeVariant(`U`),
+		},
+	}
+	for _, tt := range []struct {
+		template, want string
+	}{
+		{`x[|{title}]`, `xT`},
+		{`{title}`, `T`},
+		{`{artist} - {title}`, `A - T`},
+		{`{album}|{title}`, `T`},
+		{`{album}|{genre}`, ``},
+		{`{title}[ ({album})]`, `T`},
+		{`{title}[ ({url})]`, `T (U)`},
+		{`[{album}|{url}]`, `U`},
+		{`\{title\} \[\|\\`, `{title} [|\`},
+		{`{player}: {xesam:title}`, `spotify: T`},
+	} {
+		template, err := parseTemplate(tt.template)
+		if err != nil {
+			t.Errorf("parseTemplate(%q): %v", tt.template, err)
+			continue
+		}
+		if got := p.Render(template); got != tt.want {
+			t.Errorf("render %q: got %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestTemplateForMostSpecific(t *testing.T) {
+	saved := playerTemplates
+	defer func() { playerTemplates = saved }()
+	playerTemplates = make(map[string]nowPlayingTemplate)
+	for key, s := range map[string]string{
+		`firefox`: `short`,
+		`firefox.instance42`: `long`,
+		`Firefox Web Browser`: `identity`,
+	} {
+		playerTemplates[key], _ = parseTemplate(s)
+	}
+	p := &Properties{Player: `firefox.instance42`, Identity: `Firefox`}
+	// map order changes from run to run
+	for i := 0; i < 20; i++ {
+		template, found := templateFor(p)
+		if got := p.Render(template); !(found) || got != `long` {
+			t.Fatalf("got %q, want %q", got, `long`)
+		}
+	}
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
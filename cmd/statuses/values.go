@@ -0,0 +1,125 @@
+package main
+
+import (
+	"sync"
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+// Values exposes the raw numeric value of each status, taken from the
+// statuses on their way to the service, so that widgets can compare it with
+// thresholds.
+
+const (
+	valuesIface = `com.github.canalguada.gostatuses.Values`
+	valuesPath = `/com/github/canalguada/gostatuses/Values`
+)
+
+// extraValues are not statuses but are useful to widgets.
+var extraValues = []string{`VolumeMute`, `MprisPlayback`}
+
+type Values struct {
+	mu sync.Mutex
+	current map[string]float64
+	props *prop.Properties
+}
+
+func NewValues() *Values {
+	return &Values{current: make(map[string]float64)}
+}
+
+// toFloat returns the numeric value of a status, if any.
+func toFloat(value interface{}) (result float64, ok bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return
+}
+
+// Set sets the value of tag, emitting it when exported and changed.
+func (v *Values) Set(tag string, value float64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.current[tag] = value
+	if v.props == nil {
+		return
+	}
+	if current, err := v.props.Get(valuesIface, tag); err == nil {
+		if current.Value() != value {
+			v.props.SetMust(valuesIface, tag, value)
+		}
+	}
+}
+
+// Tee records the values of the statuses read from in, then forwards them
+// to out, that is closed with in.
+func (v *Values) Tee(in <-chan Status, out chan<- Status) {
+	for s := range in {
+		if value, ok := toFloat(s.Value); ok {
+			v.Set(s.Tag, value)
+		}
+		out <- s
+	}
+	close(out)
+}
+
+// Export exports the values of the resources statuses on conn.
+func (v *Values) Export(
+	conn *dbus.Conn,
+	resources map[string]*Resource,
+) (err error) {
+	tags := append([]string(nil), extraValues...)
+	for _, rc := range resources {
+		tags = append(tags, rc.ListStatuses()...)
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	spec := prop.Map{valuesIface: make(map[string]*prop.Prop)}
+	for _, tag := range tags {
+		spec[valuesIface][tag] = &prop.Prop{
+			Value: v.current[tag],
+			Writable: false,
+			Emit: prop.EmitTrue,
+			Callback: nil,
+		}
+	}
+	props, err := prop.Export(conn, dbus.ObjectPath(valuesPath), spec)
+	if err != nil {
+		return
+	}
+	n := &introspect.Node{
+		Name: valuesPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       valuesIface,
+				Properties: props.Introspection(valuesIface),
+			},
+		},
+	}
+	if err = conn.Export(
+		introspect.NewIntrospectable(n),
+		dbus.ObjectPath(valuesPath),
+		"org.freedesktop.DBus.Introspectable",
+	); err != nil {
+		return
+	}
+	v.props = props
+	return
+}
+// Values end
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
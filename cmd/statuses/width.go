@@ -0,0 +1,148 @@
+package main
+
+import (
+	"unicode"
+)
+
+// wideRanges lists the East Asian wide and fullwidth ranges, plus the
+// emoji blocks, that take two terminal cells.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115f},
+	{0x231a, 0x231b},
+	{0x2329, 0x232a},
+	{0x23e9, 0x23ec},
+	{0x23f0, 0x23f0},
+	{0x23f3, 0x23f3},
+	{0x25fd, 0x25fe},
+	{0x2614, 0x2615},
+	{0x2648, 0x2653},
+	{0x267f, 0x267f},
+	{0x2693, 0x2693},
+	{0x26a1, 0x26a1},
+	{0x26aa, 0x26ab},
+	{0x26bd, 0x26be},
+	{0x26c4, 0x26c5},
+	{0x26ce, 0x26ce},
+	{0x26d4, 0x26d4},
+	{0x26ea, 0x26ea},
+	{0x26f2, 0x26f3},
+	{0x26f5, 0x26f5},
+	{0x26fa, 0x26fa},
+	{0x26fd, 0x26fd},
+	{0x2705, 0x2705},
+	{0x270a, 0x270b},
+	{0x2728, 0x2728},
+	{0x274c, 0x274c},
+	{0x274e, 0x274e},
+	{0x2753, 0x2755},
+	{0x2757, 0x2757},
+	{0x2795, 0x2797},
+	{0x27b0, 0x27b0},
+	{0x27bf, 0x27bf},
+	{0x2b1b, 0x2b1c},
+	{0x2b50, 0x2b50},
+	{0x2b55, 0x2b55},
+	{0x2e80, 0x303e},
+	{0x3041, 0x33ff},
+	{0x3400, 0x4dbf},
+	{0x4e00, 0x9fff},
+	{0xa000, 0xa4cf},
+	{0xa960, 0xa97f},
+	{0xac00, 0xd7a3},
+	{0xf900, 0xfaff},
+	{0xfe10, 0xfe19},
+	{0xfe30, 0xfe6f},
+	{0xff00, 0xff60},
+	{0xffe0, 0xffe6},
+	{0x16fe0, 0x16fe4},
+	{0x17000, 0x18cff},
+	{0x1b000, 0x1b2ff},
+	{0x1f004, 0x1f004},
+	{0x1f0cf, 0x1f0cf},
+	{0x1f18e, 0x1f18e},
+	{0x1f191, 0x1f19a},
+	{0x1f200, 0x1f251},
+	{0x1f300, 0x1f64f},
+	{0x1f680, 0x1f6ff},
+	{0x1f7e0, 0x1f7eb},
+	{0x1f90c, 0x1f9ff},
+	{0x1fa70, 0x1faff},
+	{0x20000, 0x2fffd},
+	{0x30000, 0x3fffd},
+}
+
+// runeWidth returns the number of terminal cells used by r.
+func runeWidth(r rune) int {
+	switch {
+	case r == 0:
+		return 0
+	case r < 0x20 || (r >= 0x7f && r < 0xa0):
+		return 0
+	case r == 0x200b || (r >= 0x200c && r <= 0x200f):
+		return 0
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	}
+	if r < wideRanges[0][0] {
+		return 1
+	}
+	lo, hi := 0, len(wideRanges) - 1
+	for lo <= hi {
+		mid := (lo + hi) / 2
+		switch {
+		case r < wideRanges[mid][0]:
+			hi = mid - 1
+		case r > wideRanges[mid][1]:
+			lo = mid + 1
+		default:
+			return 2
+		}
+	}
+	return 1
+}
+
+// stringWidth returns the number of terminal cells used by s.
+func stringWidth(s string) (width int) {
+	for _, r := range s {
+		width += runeWidth(r)
+	}
+	return
+}
+
+// fitWidth returns the longest prefix of s using at most width cells.
+func fitWidth(s string, width int) string {
+	var used int
+	for i, r := range s {
+		w := runeWidth(r)
+		if used + w > width {
+			return s[:i]
+		}
+		used += w
+	}
+	return s
+}
+
+// truncateWidth cuts s to width cells, ellipsis included.
+func truncateWidth(s string, width int, ellipsis string) string {
+	if stringWidth(s) <= width {
+		return s
+	}
+	room := width - stringWidth(ellipsis)
+	if room < 0 {
+		return fitWidth(ellipsis, width)
+	}
+	return fitWidth(s, room) + ellipsis
+}
+
+// marquee returns the window of width cells starting at the offset-th rune
+// of s, looping over s followed by gap.
+func marquee(s, gap string, width, offset int) string {
+	runes := []rune(s + gap)
+	if len(runes) == 0 {
+		return ""
+	}
+	offset %= len(runes)
+	return fitWidth(string(runes[offset:]) + string(runes[:offset]), width)
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
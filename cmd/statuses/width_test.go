@@ -0,0 +1,105 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestStringWidth(t *testing.T) {
+	for _, tt := range []struct {
+		s string
+		want int
+	}{
+		{``, 0},
+		{`abc`, 3},
+		{`é`, 1},
+		{`日本語`, 6},
+		{`한국어`, 6},
+		{`a😀b`, 4},
+		{`⚡`, 2},
+		{"e\u0301", 1},
+		{"a\u200bb", 2},
+		{"a\u200db", 2},
+		{"a\tb\n", 2},
+	} {
+		if got := stringWidth(tt.s); got != tt.want {
+			t.Errorf("stringWidth(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestFitWidth(t *testing.T) {
+	for _, tt := range []struct {
+		s string
+		width int
+		want string
+	}{
+		{`abc`, 5, `abc`},
+		{`abc`, 2, `ab`},
+		{`abc`, 0, ``},
+		{`日本語`, 4, `日本`},
+		// a wide rune never gets split
+		{`日本語`, 3, `日`},
+		{`a😀b`, 2, `a`},
+		// combining marks stay with their base
+		{"e\u0301x", 1, "e\u0301"},
+	} {
+		if got := fitWidth(tt.s, tt.width); got != tt.want {
+			t.Errorf(
+				"fitWidth(%q, %d) = %q, want %q",
+				tt.s, tt.width, got, tt.want,
+			)
+		}
+	}
+}
+
+func TestTruncateWidth(t *testing.T) {
+	for _, tt := range []struct {
+		s string
+		width int
+		ellipsis, want string
+	}{
+		{`hello`, 5, `…`, `hello`},
+		{`hello world`, 8, `…`, `hello w…`},
+		{`日本語テキスト`, 5, `…`, `日本…`},
+		{`日本語テキスト`, 6, `…`, `日本…`},
+		{`a😀b😀`, 4, `…`, `a😀…`},
+		// width smaller than the ellipsis
+		{`hello`, 2, `...`, `..`},
+		{`hello`, 0, `…`, ``},
+		{`hello`, 4, ``, `hell`},
+	} {
+		if got := truncateWidth(tt.s, tt.width, tt.ellipsis); got != tt.want {
+			t.Errorf(
+				"truncateWidth(%q, %d, %q) = %q, want %q",
+				tt.s, tt.width, tt.ellipsis, got, tt.want,
+			)
+		}
+	}
+}
+
+func TestMarquee(t *testing.T) {
+	for _, tt := range []struct {
+		s, gap string
+		width, offset int
+		want string
+	}{
+		{`abc`, ` `, 3, 0, `abc`},
+		{`abc`, ` `, 3, 2, `c a`},
+		// wrap around s followed by gap
+		{`abc`, ` `, 3, 4, `abc`},
+		{`abc`, ` `, 3, 5, `bc `},
+		{`abc`, ` | `, 4, 3, ` | a`},
+		{`日本`, `|`, 3, 1, `本|`},
+		{`日本`, `|`, 4, 2, `|日`},
+		{``, ``, 3, 1, ``},
+	} {
+		if got := marquee(tt.s, tt.gap, tt.width, tt.offset); got != tt.want {
+			t.Errorf(
+				"marquee(%q, %q, %d, %d) = %q, want %q",
+				tt.s, tt.gap, tt.width, tt.offset, got, tt.want,
+			)
+		}
+	}
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"github.com/godbus/dbus/v5"
+)
+
+// controls maps the prefixes of the actions to the control objects of the
+// statuses daemon.
+var controls = map[string]string{
+	"Mpris": "Mpris",
+	"Pulse": "Pulse",
+}
+
+var (
+	actions = make(keyValues)
+	buttons = map[string]int{
+		"left": 1,
+		"middle": 2,
+		"right": 3,
+		"up": 4,
+		"down": 5,
+	}
+)
+
+func init() {
+	flag.Var(
+		actions,
+		"action",
+		"action bound to a tag and a button, like 'Mpris:left=Mpris.PlayPause', " +
+			"'Mpris:up=Mpris.NextPlayer', 'Volume:up=Pulse.ChangeVolume int32:5', " +
+			"'Volume:left=Pulse.ToggleMute' or 'Volume:right=!pavucontrol' " +
+			"(repeatable)",
+	)
+}
+
+// GetAction returns the action bound to the tag and the button, if any.
+// Buttons are numbered like X11 ones, or named left, middle, right, up and
+// down.
+func GetAction(tag string, button int) (action string, found bool) {
+	for name, number := range buttons {
+		if number != button {
+			continue
+		}
+		if action, found = actions[tag + ":" + name]; found {
+			return action, len(action) > 0
+		}
+	}
+	action, found = actions[fmt.Sprintf("%s:%d", tag, button)]
+	return action, found && len(action) > 0
+}
+
+// parseMethod returns the object path, the interface and the method of the
+// statuses daemon called by an action.
+func parseMethod(name string) (path, iface, method string) {
+	path, iface, method = objectPath, busName, name
+	tokens := strings.SplitN(name, ".", 2)
+	if len(tokens) == 2 {
+		if control, found := controls[tokens[0]]; found {
+			path = objectPath + "/" + control
+			iface = busName + "." + control
+			method = tokens[1]
+		}
+	}
+	return
+}
+
+// parseArg returns the value of an action argument, typed like dbus-send
+// ones when prefixed with int32:, uint32:, double:, boolean: or string:.
+func parseArg(arg string) (value interface{}, err error) {
+	tokens := strings.SplitN(arg, ":", 2)
+	if len(tokens) != 2 {
+		return arg, nil
+	}
+	switch tokens[0] {
+	case "int32":
+		var v int64
+		v, err = strconv.ParseInt(tokens[1], 10, 32)
+		value = int32(v)
+	case "uint32":
+		var v uint64
+		v, err = strconv.ParseUint(tokens[1], 10, 32)
+		value = uint32(v)
+	case "double":
+		value, err = strconv.ParseFloat(tokens[1], 64)
+	case "boolean":
+		value, err = strconv.ParseBool(tokens[1])
+	case "string":
+		value = tokens[1]
+	default:
+		value = arg
+	}
+	return
+}
+
+// RunAction runs an action, either a shell command when starting with '!',
+// or a method of the statuses daemon followed by its arguments, like
+// "Mpris.PinPlayer spotify" or "Pulse.ChangeVolume int32:5". Methods of the
+// control objects are prefixed with their name.
+func RunAction(conn *dbus.Conn, action string) error {
+	if strings.HasPrefix(action, "!") {
+		cmd := exec.Command("sh", "-c", action[1:])
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		// reap the process when done
+		go cmd.Wait()
+		return nil
+	}
+	fields := strings.Fields(action)
+	if len(fields) == 0 {
+		return fmt.Errorf("empty action")
+	}
+	path, iface, method := parseMethod(fields[0])
+	var args []interface{}
+	for _, arg := range fields[1:] {
+		value, err := parseArg(arg)
+		if err != nil {
+			return fmt.Errorf("invalid argument %q: %w", arg, err)
+		}
+		args = append(args, value)
+	}
+	obj := conn.Object(busName, dbus.ObjectPath(path))
+	return obj.Call(iface + "." + method, 0, args...).Err
+}
+
+func isTyped(arg string) bool {
+	switch strings.SplitN(arg, ":", 2)[0] {
+	case "int32", "uint32", "double", "boolean", "string":
+		return strings.Contains(arg, ":")
+	}
+	return false
+}
+
+// ActionCommand returns the shell command running an action, calling the
+// statuses daemon methods with dbus-send.
+func ActionCommand(action string) string {
+	if strings.HasPrefix(action, "!") {
+		return action[1:]
+	}
+	fields := strings.Fields(action)
+	if len(fields) == 0 {
+		return ""
+	}
+	path, iface, method := parseMethod(fields[0])
+	command := []string{
+		"dbus-send",
+		"--session",
+		"--type=method_call",
+		"--dest=" + busName,
+		path,
+		iface + "." + method,
+	}
+	for _, arg := range fields[1:] {
+		if !(isTyped(arg)) {
+			arg = "string:" + arg
+		}
+		command = append(command, "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'")
+	}
+	return strings.Join(command, " ")
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Example: CpuPercent, with -rule 'CpuPercent.urgent=>98'
+// waybar gets the urgent class and i3bar the urgent flag, the other bars
+// hide then show the status on each blink.
+
+const urgentState = "urgent"
+
+var blinkInterval = flag.Duration(
+	"blink-interval",
+	500 * time.Millisecond,
+	"interval between the blinks of the statuses in the urgent state",
+)
+
+// urgentFormatter is implemented by the formatters of bars that show the
+// urgent statuses themselves.
+type urgentFormatter interface {
+	Urgent() bool
+}
+
+// blinks reports whether the urgent statuses blink with the formatter.
+func blinks(f Formatter) bool {
+	for _, r := range rules {
+		if r.State == urgentState {
+			_, native := f.(urgentFormatter)
+			return !(native)
+		}
+	}
+	return false
+}
+
+// blank replaces the characters of s with spaces, keeping its width.
+func blank(s string) string {
+	return strings.Repeat(" ", utf8.RuneCountInString(s))
+}
+
+// Blink hides or shows the urgent statuses, and reports whether any is
+// currently urgent.
+func (w *Widget) Blink() (updated bool) {
+	w.hidden = !(w.hidden)
+	for _, tag := range w.ordered {
+		if w.Statuses[tag].State == urgentState {
+			updated = true
+		}
+	}
+	return
+}
+
+// visible returns the status as shown, blanked while an urgent status is
+// hidden.
+func (w *Widget) visible(ws *widgetStatus) *widgetStatus {
+	if !(w.hidden) || ws.State != urgentState {
+		return ws
+	}
+	hidden := *ws
+	hidden.Label = blank(ws.Label)
+	hidden.Text = blank(ws.Text)
+	hidden.Value = blank(ws.Value)
+	hidden.Sparkline = blank(ws.Sparkline)
+	return &hidden
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
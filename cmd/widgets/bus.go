@@ -0,0 +1,113 @@
+package main
+
+import (
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	busName = "com.github.canalguada.gostatuses"
+	objectPath = "/com/github/canalguada/gostatuses"
+	propertiesIface = "org.freedesktop.DBus.Properties"
+	valuesIface = "com.github.canalguada.gostatuses.Values"
+	valuesPath = "/com/github/canalguada/gostatuses/Values"
+)
+
+// Subscribe asks the bus for the PropertiesChanged signals sent by the
+// statuses daemon, for its statuses and values, and for its owner changes,
+// then returns the unique name currently owning busName, if any.
+func Subscribe(conn *dbus.Conn, c chan *dbus.Signal) (owner string, err error) {
+	if err = conn.AddMatchSignal(
+		dbus.WithMatchSender(busName),
+		dbus.WithMatchObjectPath(dbus.ObjectPath(objectPath)),
+		dbus.WithMatchInterface(propertiesIface),
+		dbus.WithMatchMember("PropertiesChanged"),
+	); err != nil {
+		return
+	}
+	if err = conn.AddMatchSignal(
+		dbus.WithMatchSender(busName),
+		dbus.WithMatchObjectPath(dbus.ObjectPath(valuesPath)),
+		dbus.WithMatchInterface(propertiesIface),
+		dbus.WithMatchMember("PropertiesChanged"),
+	); err != nil {
+		return
+	}
+	if err = conn.AddMatchSignal(
+		dbus.WithMatchSender("org.freedesktop.DBus"),
+		dbus.WithMatchObjectPath("/org/freedesktop/DBus"),
+		dbus.WithMatchInterface("org.freedesktop.DBus"),
+		dbus.WithMatchMember("NameOwnerChanged"),
+		dbus.WithMatchArg(0, busName),
+	); err != nil {
+		return
+	}
+	conn.Signal(c)
+	return GetOwner(conn), nil
+}
+
+// GetOwner returns the unique name owning busName, or an empty string.
+func GetOwner(conn *dbus.Conn) (owner string) {
+	conn.BusObject().Call(
+		"org.freedesktop.DBus.GetNameOwner",
+		0,
+		busName,
+	).Store(&owner)
+	return
+}
+
+// Activate asks the bus to start the statuses daemon and returns its unique
+// name.
+func Activate(conn *dbus.Conn) (owner string, err error) {
+	var reply uint32
+	if err = conn.BusObject().Call(
+		"org.freedesktop.DBus.StartServiceByName",
+		0,
+		busName,
+		uint32(0),
+	).Store(&reply); err != nil {
+		return
+	}
+	owner = GetOwner(conn)
+	return
+}
+
+// NewOwner returns the new owner from a NameOwnerChanged signal body about
+// busName.
+func NewOwner(body []interface{}) (owner string) {
+	if len(body) == 3 {
+		if name, ok := body[0].(string); ok && name == busName {
+			owner, _ = body[2].(string)
+		}
+	}
+	return
+}
+
+// Apply updates the statuses from the body of a PropertiesChanged signal and
+// reports whether any tracked status changed.
+func (w *Widget) Apply(body []interface{}) (updated bool) {
+	if len(body) < 2 {
+		return
+	}
+	if iface, ok := body[0].(string); !ok || iface != busName {
+		return
+	}
+	changed, ok := body[1].(map[string]dbus.Variant)
+	if !ok {
+		return
+	}
+	for tag, variant := range changed {
+		var value DbusStatus
+		// check if tracking this property
+		if !(w.HasTag(tag)) {
+			continue
+		}
+		// update widgetStatus
+		if err := variant.Store(&value); err == nil && value != w.GetStatus(tag) {
+			w.Update(tag, value)
+			updated = true
+		}
+	}
+	return
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
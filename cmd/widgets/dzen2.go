@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Example: CpuPercent
+// ^bg(#cc0000) ^fg(#c0392b)^fg()  92% ^bg()
+
+type dzen2Formatter struct {
+	baseFormatter
+}
+
+// dzen2 has no border: the border option is ignored.
+func (f dzen2Formatter) Format(tag string, c Color) (format string) {
+	format = Padded("[CONTENT]")
+	if *background {
+		format = "^bg(" + c.Rgb().Background + ")" + format + "^bg()"
+	}
+	return
+}
+
+// escape protects text from being parsed as dzen2 commands.
+func escape(text string) string {
+	return strings.ReplaceAll(text, "^", "^^")
+}
+
+func (f dzen2Formatter) Content(tag string, ws *widgetStatus) string {
+	if raw, found := RenderTemplate(tag, ws, escape); found {
+		return raw
+	}
+	raw := escape(ws.Text)
+	if !(*text) && len(ws.Label) > 0 {
+		if *highlight {
+			raw = fmt.Sprintf(
+				"^fg(%s)%s^fg() %s",
+				ws.Color.Rgb().Highlight,
+				escape(ws.Label),
+				raw,
+			)
+		} else {
+			raw = escape(ws.Label) + raw
+		}
+	}
+	return appendSparkline(raw, ws, func(s string) string {
+		return fmt.Sprintf("^fg(%s)%s^fg()", ws.Color.Rgb().Highlight, s)
+	})
+}
+
+func (f dzen2Formatter) Placeholder(text string) string {
+	return escape(text)
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
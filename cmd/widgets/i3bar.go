@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"github.com/godbus/dbus/v5"
+)
+
+// Example: CpuPercent
+// {"background":"#cc00007f","full_text":"  92%","name":"CpuPercent",...},
+
+// i3barHeader enables click events.
+const i3barHeader = `{"version":1,"click_events":true}` + "\n["
+
+// Argb converts a #AARRGGBB color into #RRGGBBAA, as i3bar expects.
+func Argb(color string) string {
+	if reARGB.MatchString(color) {
+		return "#" + color[3:] + color[1:3]
+	}
+	return color
+}
+
+type i3barFormatter struct {
+	baseFormatter
+}
+
+func (f i3barFormatter) Header() string {
+	return i3barHeader
+}
+
+func (f i3barFormatter) Format(tag string, c Color) string {
+	return "[CONTENT]"
+}
+
+// Urgent reports that i3bar shows the urgent statuses itself.
+func (f i3barFormatter) Urgent() bool {
+	return true
+}
+
+// Content returns the JSON block of the tag status.
+func (f i3barFormatter) Content(tag string, ws *widgetStatus) string {
+	c := ws.Color
+	block := map[string]interface{}{
+		"full_text": Padded(f.baseFormatter.Content(tag, ws)),
+		"name": tag,
+		"instance": busName,
+		"separator": false,
+		"separator_block_width": *spacing,
+	}
+	if *highlight {
+		block["color"] = Argb(c.Highlight)
+	}
+	if ws.State == urgentState {
+		block["urgent"] = true
+	}
+	if *background {
+		block["background"] = Argb(c.Background)
+	}
+	switch *border {
+	case `overline`, `underline`:
+		block["border"] = Argb(c.Highlight)
+		block["border_left"] = 0
+		block["border_right"] = 0
+		if *border == `overline` {
+			block["border_bottom"] = 0
+		} else {
+			block["border_top"] = 0
+		}
+	}
+	data, _ := json.Marshal(block)
+	return string(data)
+}
+
+func (f i3barFormatter) Join(w *Widget, items []string) string {
+	return "[" + strings.Join(items, ",") + "],"
+}
+
+func (f i3barFormatter) Placeholder(raw string) string {
+	data, _ := json.Marshal(map[string]string{"full_text": raw})
+	return "[" + string(data) + "],"
+}
+
+// clickEvent is sent by i3bar on stdin.
+type clickEvent struct {
+	Name string `json:"name"`
+	Instance string `json:"instance"`
+	Button int `json:"button"`
+}
+
+// ReadClickEvents runs the actions bound to the clicks read from r, until
+// r is closed.
+func ReadClickEvents(conn *dbus.Conn, r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// the events are items of an endless array
+		line := strings.TrimLeft(strings.TrimSpace(scanner.Text()), "[,")
+		if len(line) == 0 {
+			continue
+		}
+		var event clickEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to read click event:", err)
+			continue
+		}
+		if *debug {
+			fmt.Fprintf(os.Stderr, "Click event: %+v\n", event)
+		}
+		if action, found := GetAction(event.Name, event.Button); found {
+			if err := RunAction(conn, action); err != nil {
+				fmt.Fprintln(os.Stderr, "Failed to run", action, "action:", err)
+			}
+		}
+	}
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Example: CpuPercent
+// %{U#c0392b}%{+o}%{B#7fcc0000} %{F#c0392b}%{F-}  92% %{B-}%{-o}%{U-}
+
+type lemonbarFormatter struct {
+	baseFormatter
+}
+
+func (f lemonbarFormatter) Format(tag string, c Color) (format string) {
+	format = Padded("[CONTENT]")
+	if *background {
+		format = "%{B" + c.Background + "}" + format + "%{B-}"
+	}
+	switch *border {
+	case `overline`:
+		format = "%{U" + c.Highlight + "}%{+o}" + format + "%{-o}%{U-}"
+	case `underline`:
+		format = "%{U" + c.Highlight + "}%{+u}" + format + "%{-u}%{U-}"
+	}
+	return
+}
+
+// escapePercent escapes percent signs by doubling them, like lemonbar does.
+func escapePercent(text string) string {
+	return strings.ReplaceAll(text, "%", "%%")
+}
+
+func (f lemonbarFormatter) Content(tag string, ws *widgetStatus) string {
+	if raw, found := RenderTemplate(tag, ws, escapePercent); found {
+		return raw
+	}
+	raw := escapePercent(ws.Text)
+	if !(*text) && len(ws.Label) > 0 {
+		if *highlight {
+			raw = fmt.Sprintf(
+				"%%{F%s}%s%%{F-} %s",
+				ws.Color.Highlight,
+				ws.Label,
+				raw,
+			)
+		} else {
+			raw = fmt.Sprint(ws.Label, raw)
+		}
+	}
+	return appendSparkline(raw, ws, func(s string) string {
+		return fmt.Sprintf("%%{F%s}%s%%{F-}", ws.Color.Highlight, s)
+	})
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
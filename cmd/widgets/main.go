@@ -5,8 +5,8 @@ import (
 	"strings"
 	"regexp"
 	"os"
-	"sync"
 	"flag"
+	"time"
 	"github.com/godbus/dbus/v5"
 	// "github.com/canalguada/goprocfs/procmon"
 )
@@ -41,6 +41,10 @@ func (c Color) Rgb() Color {
 type widgetStatus struct {
 	DbusStatus
 	Format string
+	State string
+	Color Color
+	Value string
+	Sparkline string
 }
 
 func (ws *widgetStatus) update(value DbusStatus) {
@@ -49,15 +53,29 @@ func (ws *widgetStatus) update(value DbusStatus) {
 }
 
 var (
-	kind = flag.String("type", "polybar", "widget style")
+	kind = flag.String("type", "polybar", "widget style (polybar, tmux, i3bar, waybar, lemonbar, xmobar, dzen2, term)")
 	border = flag.String("border", "none", "use border")
 	highlight = flag.Bool("highlight", false, "use highlight")
 	background = flag.Bool("background", false, "use background")
 	text = flag.Bool("text", false, "show text only")
 	padding = flag.Int("padding", 1, "padding")
 	spacing = flag.Int("spacing", 1, "spacing")
-	separator = flag.String("separator", " ", "use separator")
+	separator = flag.String(
+		"separator",
+		" ",
+		"use separator, or powerline glyphs with tmux and polybar",
+	)
 	once = flag.Bool("once", false, "print tag(s) once")
+	offline = flag.String(
+		"offline",
+		"",
+		"placeholder printed while statuses daemon is not running",
+	)
+	activate = flag.Bool(
+		"activate",
+		false,
+		"start statuses daemon with D-Bus activation when not running",
+	)
 	debug = flag.Bool("debug", false, "debug")
 	defaultColors = map[string]Color {
 		"CpuPercent": Color{"#7fcc0000", "#c0392b"},
@@ -72,13 +90,116 @@ var (
 		"UpTotal": Color{"#5fc4a000", "#fce947"},
 		"Volume": Color{"#5f4e9a06", "#1cdc9a"},
 		"Mpris": Color{"#5f4e9a06", "#1cdc9a"},
+		"MprisProgress": Color{"#5f4e9a06", "#1cdc9a"},
+		"MprisPlayers": Color{"#5f4e9a06", "#1cdc9a"},
+		// unknown tags
+		"*": Color{"#5f555753", "#d3d7cf"},
+		// states
+		"*.high": Color{"#5fffbf00", "#ffbf00"},
+		"*.critical": Color{"#7fcc0000", "#ff4136"},
+		"*.muted": Color{"#5f555753", "#888a85"},
+		"*.urgent": Color{"#7fcc0000", "#ff4136"},
 	}
 )
 
+// Formatter renders the statuses for a given bar.
+type Formatter interface {
+	// Header returns what is printed once, before the first output.
+	Header() string
+	// Format returns the markup around the [CONTENT] of the tag status.
+	Format(tag string, c Color) string
+	// Content returns the content of the tag status.
+	Content(tag string, ws *widgetStatus) string
+	// Join returns the output from the formatted statuses.
+	Join(w *Widget, items []string) string
+	// Placeholder returns the output while the daemon is not running.
+	Placeholder(raw string) string
+}
+
+// baseFormatter implements a plain text Formatter, to be embedded by the
+// bar specific ones.
+type baseFormatter struct{}
+
+func (f baseFormatter) Header() string {
+	return ""
+}
+
+func (f baseFormatter) Format(tag string, c Color) string {
+	return Padded("[CONTENT]")
+}
+
+func (f baseFormatter) Content(tag string, ws *widgetStatus) string {
+	if raw, found := RenderTemplate(tag, ws, nil); found {
+		return raw
+	}
+	raw := ws.Text
+	if !(*text) && len(ws.Label) > 0 {
+		raw = fmt.Sprint(ws.Label, raw)
+	}
+	return appendSparkline(raw, ws, nil)
+}
+
+func (f baseFormatter) Join(w *Widget, items []string) string {
+	var pad string
+	sep := *separator
+	// not supported by this bar
+	if powerline() {
+		sep = " "
+	}
+	if *spacing >= 0 && *spacing < 5 {
+		pad = strings.Repeat(sep, *spacing)
+	}
+	return strings.Join(items, pad)
+}
+
+func (f baseFormatter) Placeholder(raw string) string {
+	return raw
+}
+
+// Padded surrounds s with padding spaces.
+func Padded(s string) string {
+	var pad string
+	if *padding >= 0 && *padding < 5 {
+		pad = strings.Repeat(` `, *padding)
+	}
+	return pad + s + pad
+}
+
+var formatters = map[string]func() Formatter{
+	"polybar": func() Formatter { return polybarFormatter{} },
+	"tmux": func() Formatter { return tmuxFormatter{} },
+	"i3bar": func() Formatter { return i3barFormatter{} },
+	"waybar": func() Formatter { return waybarFormatter{} },
+	"lemonbar": func() Formatter { return lemonbarFormatter{} },
+	"xmobar": func() Formatter { return xmobarFormatter{} },
+	"dzen2": func() Formatter { return dzen2Formatter{} },
+	"term": newTermFormatter,
+}
+
 type Widget struct {
 	Statuses map[string]*widgetStatus
 	ordered []string
-	getter func(string, *widgetStatus) string
+	formatter Formatter
+	started bool
+	values map[string]float64
+	histories map[string]*history
+	// hidden is set while the urgent statuses blink off
+	hidden bool
+}
+
+func NewWidget(tags []string, f Formatter) *Widget {
+	w := &Widget{
+		formatter: f,
+		values: make(map[string]float64),
+		histories: make(map[string]*history),
+	}
+	w.Statuses = make(map[string]*widgetStatus)
+	for _, tag := range tags {
+		c := GetColor(tag, "")
+		w.Statuses[tag] = &widgetStatus{Format: f.Format(tag, c), Color: c}
+		w.ordered = append(w.ordered, tag)
+	}
+	return w
 }
 
 func (w *Widget) Update(tag string, s DbusStatus) {
@@ -86,12 +207,31 @@ func (w *Widget) Update(tag string, s DbusStatus) {
 	ws.update(s)
 }
 
+// isUnknownProperty reports whether err is the reply to a request for a
+// property that the daemon does not publish.
+func isUnknownProperty(err error) bool {
+	if e, ok := err.(dbus.Error); ok {
+		switch e.Name {
+		case "org.freedesktop.DBus.Properties.Error.PropertyNotFound",
+			"org.freedesktop.DBus.Error.InvalidArgs",
+			"org.freedesktop.DBus.Error.UnknownProperty":
+			return true
+		}
+	}
+	return false
+}
+
+// Initialize gets the statuses from the daemon. The tags it does not
+// publish, like a typo or a resource it does not monitor, are left empty.
 func (w *Widget) Initialize(conn *dbus.Conn, iface, path string) error {
 	obj := conn.Object(iface, dbus.ObjectPath(path))
 	for _, tag := range w.ordered {
 		var s DbusStatus
 		property := fmt.Sprintf("%s.%s", iface, tag)
-		if v, err := obj.GetProperty(property); err != nil {
+		if v, err := obj.GetProperty(property); isUnknownProperty(err) {
+			fmt.Fprintln(os.Stderr, "Unknown", tag, "property, skipped:", err)
+			continue
+		} else if err != nil {
 			fmt.Fprintln(os.Stderr, "Failed to get", tag, "property:", err)
 			return err
 		} else if err := v.Store(&s); err != nil {
@@ -103,6 +243,7 @@ func (w *Widget) Initialize(conn *dbus.Conn, iface, path string) error {
 		}
 		w.Update(tag, s)
 	}
+	w.InitializeValues(conn)
 	return nil
 }
 
@@ -117,127 +258,33 @@ func (w *Widget) GetStatus(tag string) DbusStatus {
 
 func (w *Widget) get(tag string) string {
 	ws := w.Statuses[tag]
-	raw := (w.getter)(tag, ws)
+	raw := w.formatter.Content(tag, w.visible(ws))
 	return strings.Replace(ws.Format, "[CONTENT]", raw, 1)
 
 }
 
-func (w *Widget) String() string {
-	var items []string
-	var pad string
-	if *spacing >= 0 && *spacing < 5 {
-		pad = strings.Repeat(*separator, *spacing)
-	}
-	for _, tag := range w.ordered {
-		items = append(items, w.get(tag))
-	}
-	return strings.Join(items, pad)
-}
-
-// type widgetPointer interface {
-//   Update(string, DbusStatus)
-//   Initialize(*dbus.Conn, string, string) error
-//   HasTag(string) bool
-//   GetStatus(string) DbusStatus
-//   String() string
-// }
-
-// Example: CpuPercent
-// %{o#c0392b}%{+o}%{B#7fcc0000} %{F#c0392b} %{F-}  92% %{B-}%{-o}
-
-func newPolybarWidget(tags []string) *Widget {
-	w := &Widget{}
-	w.Statuses = make(map[string]*widgetStatus)
-	var s string
-	if *padding >= 0 && *padding < 5 {
-		s = strings.Repeat(` `, *padding)
-	}
-	for _, tag := range tags {
-		if c, found := defaultColors[tag]; found {
-			ws := &widgetStatus{Format: "[CONTENT]"}
-			ws.Format = s + ws.Format + s
-			if *background {
-				ws.Format = "%{B" + c.Background + "}" + ws.Format + "%{B-}"
-			}
-			switch *border {
-			case `overline`:
-				ws.Format = "%{o" + c.Highlight + "}%{+o}" + ws.Format + "%{-o}"
-			case `underline`:
-				ws.Format = "%{u" + c.Highlight + "}%{+u}" + ws.Format + "%{-u}"
-			}
-			w.Statuses[tag] = ws
-			w.ordered = append(w.ordered, tag)
+// Print prints the widget, or the offline placeholder, after the header
+// when any.
+func (w *Widget) Print(online bool) {
+	if !(w.started) {
+		if header := w.formatter.Header(); len(header) > 0 {
+			fmt.Println(header)
 		}
+		w.started = true
 	}
-	w.getter = func(tag string, ws *widgetStatus) string {
-		raw := ws.Text
-		if !(*text) && len(ws.Label) > 0 {
-			if *highlight {
-				raw = fmt.Sprintf(
-					"%%{F%s}%s%%{F-} %s",
-					defaultColors[tag].Highlight,
-					ws.Label,
-					raw,
-				)
-			} else {
-				raw = fmt.Sprint(ws.Label, raw)
-			}
-		}
-		return raw
+	if online {
+		fmt.Println(w)
+	} else {
+		fmt.Println(w.formatter.Placeholder(*offline))
 	}
-	return w
 }
 
-func newTmuxNovaWidget(tags []string) *Widget {
-	w := &Widget{}
-	w.Statuses = make(map[string]*widgetStatus)
-	var s string
-	if *padding >= 0 && *padding < 5 {
-		s = strings.Repeat(` `, *padding)
-	}
-	for _, tag := range tags {
-		if c, found := defaultColors[tag]; found {
-			ws := &widgetStatus{Format: "[CONTENT]"}
-			ws.Format = s + ws.Format + s
-			var fg string = "default"
-			var bg string = "default"
-			if *highlight {
-				fg = c.Rgb().Highlight  // "#ffffff"
-			}
-			if *background {
-				bg = c.Rgb().Background  // "#000000"
-			}
-			ws.Format = "#[fg=" + fg + ",bg=" + bg + "]" + ws.Format
-			ws.Format += "#[fg=default,bg=default]"
-			w.Statuses[tag] = ws
-			w.ordered = append(w.ordered, tag)
-		}
-	}
-	w.getter = func(tag string, ws *widgetStatus) string {
-		raw := ws.Text
-		if !(*text) && len(ws.Label) > 0 {
-			raw = fmt.Sprint(ws.Label, raw)
-		}
-		return raw
+func (w *Widget) String() string {
+	var items []string
+	for _, tag := range w.ordered {
+		items = append(items, w.get(tag))
 	}
-	return w
-}
-
-type rule struct {
-	Type string
-	Member string
-	Path string
-	Interface string
-}
-
-func (r rule) String() string {
-	return fmt.Sprintf(
-		"type='%s',member='%s',path='%s',interface='%s'",
-		r.Type,
-		r.Member,
-		r.Path,
-		r.Interface,
-	)
+	return w.formatter.Join(w, items)
 }
 
 func main() {
@@ -251,84 +298,105 @@ func main() {
 	if len(tags) == 0 {
 		os.Exit(1)
 	}
+	if *sparklineWidth < 1 {
+		fmt.Fprintln(os.Stderr, "Invalid sparkline width:", *sparklineWidth)
+		os.Exit(1)
+	}
+	if *sparklineInterval <= 0 {
+		fmt.Fprintln(os.Stderr, "Invalid sparkline interval:", *sparklineInterval)
+		os.Exit(1)
+	}
+	if *blinkInterval <= 0 {
+		fmt.Fprintln(os.Stderr, "Invalid blink interval:", *blinkInterval)
+		os.Exit(1)
+	}
 	conn, err := dbus.ConnectSessionBus()
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Failed to connect to session bus:", err)
 		os.Exit(1)
 	}
 	defer conn.Close()
+	if len(*theme) > 0 {
+		resources, err := LoadTheme(*theme)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to load theme:", err)
+			os.Exit(1)
+		}
+		ApplyTheme(resources)
+	}
 	// Widget initialization
-	var w *Widget
-	switch *kind {
-	case "polybar":
-		w = newPolybarWidget(tags)
-	case "tmux":
-		w = newTmuxNovaWidget(tags)
-	default:
+	newFormatter, found := formatters[*kind]
+	if !(found) {
 		os.Exit(1)
 	}
-	if err := w.Initialize(
-		conn,
-		"com.github.canalguada.gostatuses",
-		"/com/github/canalguada/gostatuses",
-	); err != nil {
+	w := NewWidget(tags, newFormatter())
+	if *kind == "i3bar" && !(*once) {
+		go ReadClickEvents(conn, os.Stdin)
+	}
+	// Subscribe to the statuses daemon signals only
+	c := make(chan *dbus.Signal, 10)
+	owner, err := Subscribe(conn, c)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to subscribe to signals:", err)
 		os.Exit(1)
 	}
-	fmt.Println(w)
+	if len(owner) == 0 && *activate {
+		if owner, err = Activate(conn); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to activate service:", err)
+		}
+	}
+	online := len(owner) > 0 && w.Initialize(conn, busName, objectPath) == nil
+	w.Print(online)
 	// Exit when required
 	if *once {
+		if !(online) {
+			os.Exit(1)
+		}
 		os.Exit(0)
 	}
-	// Monitoring
-	rules := []string{
-		rule{
-			`signal`,
-			`PropertiesChanged`,
-			`/com/github/canalguada/gostatuses`,
-			`org.freedesktop.DBus.Properties`,
-		}.String(),
+	// sample the values followed by sparklines
+	var samples <-chan time.Time
+	if len(*sparklines) > 0 {
+		samples = time.NewTicker(*sparklineInterval).C
 	}
-	var flag uint = 0
-	call := conn.BusObject().Call(
-		"org.freedesktop.DBus.Monitoring.BecomeMonitor",
-		0,
-		rules,
-		flag,
-	)
-	if call.Err != nil {
-		fmt.Fprintln(os.Stderr, "Failed to become monitor:", call.Err)
-		os.Exit(1)
+	// blink the urgent statuses, unless the bar does
+	var blinking <-chan time.Time
+	if blinks(w.formatter) {
+		blinking = time.NewTicker(*blinkInterval).C
 	}
-	c := make(chan *dbus.Message, 10)
-	conn.Eavesdrop(c)
-	for v := range c {
-		var updated bool
-		var wg sync.WaitGroup
-		// fmt.Printf("%#v\n", v.Body)
-		changed := (v.Body[1]).(map[string]dbus.Variant)
-		for tag, variant := range changed {
-			wg.Add(1)
-			go func(tag string, variant dbus.Variant) {
-				defer wg.Done()
-				var value DbusStatus
-				// check if tracking this property
-				if w.HasTag(tag) {
-					// get value
-					if err := variant.Store(&value); err == nil {
-						// update widgetStatus
-						if value != w.GetStatus(tag) {
-							w.Update(tag, value)
-							updated = true
-						}
-						// fmt.Printf("%s: '%s:%s'\n", tag, value.Icon, value.Text)
-					}
-				}
-			}(tag, variant)
+	for {
+		var v *dbus.Signal
+		select {
+		case <-samples:
+			if online && w.Sample() {
+				w.Print(online)
+			}
+			continue
+		case <-blinking:
+			if online && w.Blink() {
+				w.Print(online)
+			}
+			continue
+		case v = <-c:
 		}
-		wg.Wait() // wait on the workers to finish
+		switch {
+		case v.Name == "org.freedesktop.DBus.NameOwnerChanged":
+			// the statuses daemon appeared, vanished or was replaced
+			if owner = NewOwner(v.Body); len(owner) == 0 && *activate {
+				owner, _ = Activate(conn)
+			}
+			online = len(owner) > 0 && w.Initialize(conn, busName, objectPath) == nil
+			w.Print(online)
+		// the bus may deliver signals matching other rules on this connection
+		case v.Sender != owner:
+		case v.Name != propertiesIface + ".PropertiesChanged":
+		// if a state changed, print Widget
+		case v.Path == dbus.ObjectPath(valuesPath) && w.ApplyValues(v.Body):
+			w.Print(online)
+		case v.Path != dbus.ObjectPath(objectPath):
 		// if updated, print Widget
-		if updated {
-			fmt.Println(w)
+		case w.Apply(v.Body):
+			w.Print(online)
 		}
 	}
 }
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Example: CpuPercent
+// %{o#c0392b}%{+o}%{B#7fcc0000} %{F#c0392b} %{F-}  92% %{B-}%{-o}
+// With actions bound to the tag, the format is wrapped in action tags like
+// %{A1:dbus-send ...:}...%{A}, colons being escaped in commands.
+
+type polybarFormatter struct {
+	baseFormatter
+}
+
+func (f polybarFormatter) Format(tag string, c Color) (format string) {
+	format = Padded("[CONTENT]")
+	if withBackground() {
+		format = "%{B" + c.Background + "}" + format + "%{B-}"
+	}
+	switch *border {
+	case `overline`:
+		format = "%{o" + c.Highlight + "}%{+o}" + format + "%{-o}"
+	case `underline`:
+		format = "%{u" + c.Highlight + "}%{+u}" + format + "%{-u}"
+	}
+	for button := 1; button <= 5; button++ {
+		if action, found := GetAction(tag, button); found {
+			command := strings.ReplaceAll(ActionCommand(action), ":", `\:`)
+			format = fmt.Sprintf("%%{A%d:%s:}%s%%{A}", button, command, format)
+		}
+	}
+	return
+}
+
+// Join joins the segments with powerline glyphs when required.
+func (f polybarFormatter) Join(w *Widget, items []string) string {
+	if !(powerline()) {
+		return f.baseFormatter.Join(w, items)
+	}
+	return PowerlineJoin(w, items, func(fg, bg string) string {
+		if len(bg) == 0 {
+			bg = "-"
+		}
+		return "%{F" + fg + "}%{B" + bg + "}" + *powerlineGlyph + "%{F-}%{B-}"
+	})
+}
+
+func (f polybarFormatter) Content(tag string, ws *widgetStatus) string {
+	if raw, found := RenderTemplate(tag, ws, nil); found {
+		return raw
+	}
+	raw := ws.Text
+	if !(*text) && len(ws.Label) > 0 {
+		if *highlight {
+			raw = fmt.Sprintf(
+				"%%{F%s}%s%%{F-} %s",
+				ws.Color.Highlight,
+				ws.Label,
+				raw,
+			)
+		} else {
+			raw = fmt.Sprint(ws.Label, raw)
+		}
+	}
+	return appendSparkline(raw, ws, func(s string) string {
+		return fmt.Sprintf("%%{F%s}%s%%{F-}", ws.Color.Highlight, s)
+	})
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"strings"
+)
+
+// Example: tmux, CpuPercent followed by MemPercent
+// #[fg=default,bg=#cc0000]   92% #[fg=default,bg=default]
+// #[fg=#cc0000,bg=#ff79c6]#[fg=default,bg=default]
+
+var powerlineGlyph = flag.String(
+	"powerline-glyph",
+	"",
+	"glyph drawn between segments with -separator powerline",
+)
+
+// powerline reports whether the segments are joined with powerline glyphs,
+// that requires their backgrounds.
+func powerline() bool {
+	return *separator == "powerline"
+}
+
+// withBackground reports whether the segments are drawn with their
+// background.
+func withBackground() bool {
+	return *background || powerline()
+}
+
+// PowerlineJoin joins the items, each followed by a glyph drawn by arrow,
+// from the background of its segment to the background of the next one,
+// an empty string for the last one.
+func PowerlineJoin(
+	w *Widget,
+	items []string,
+	arrow func(fg, bg string) string,
+) string {
+	var b strings.Builder
+	for i, item := range items {
+		var next string
+		if i + 1 < len(items) {
+			next = w.Statuses[w.ordered[i + 1]].Color.Background
+		}
+		b.WriteString(item)
+		b.WriteString(arrow(w.Statuses[w.ordered[i]].Color.Background, next))
+	}
+	return b.String()
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
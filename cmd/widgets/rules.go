@@ -0,0 +1,208 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"github.com/godbus/dbus/v5"
+)
+
+// Rule sets the state of a tag when a value, published by the statuses
+// daemon on its Values object, compares with a threshold.
+type Rule struct {
+	Tag, State string
+	// Source is the compared value, the tag one when empty.
+	Source string
+	Operator string
+	Threshold float64
+}
+
+var reRule = regexp.MustCompile(`^(\w*)\s*(>=|<=|==|!=|>|<)\s*(-?[0-9.]+)$`)
+
+// Match reports whether the rule applies to the tag, given the values.
+func (r Rule) Match(values map[string]float64) bool {
+	source := r.Source
+	if len(source) == 0 {
+		source = r.Tag
+	}
+	value, found := values[source]
+	if !(found) {
+		return false
+	}
+	switch r.Operator {
+	case ">":
+		return value > r.Threshold
+	case ">=":
+		return value >= r.Threshold
+	case "<":
+		return value < r.Threshold
+	case "<=":
+		return value <= r.Threshold
+	case "==":
+		return value == r.Threshold
+	case "!=":
+		return value != r.Threshold
+	}
+	return false
+}
+
+func (r Rule) String() string {
+	return fmt.Sprintf(
+		"%s.%s=%s%s%v",
+		r.Tag, r.State, r.Source, r.Operator, r.Threshold,
+	)
+}
+
+// ruleList is a repeatable flag of Tag.state=[Source]operator threshold
+// rules. When many rules match, the last one sets the state.
+type ruleList []Rule
+
+func (l *ruleList) String() string {
+	var items []string
+	for _, r := range *l {
+		items = append(items, r.String())
+	}
+	return strings.Join(items, ",")
+}
+
+// Set adds a rule, replaces the rule with the same tag and state or, when
+// the expression is empty, removes it.
+func (l *ruleList) Set(s string) error {
+	tokens := strings.SplitN(s, "=", 2)
+	names := strings.SplitN(tokens[0], ".", 2)
+	if len(tokens) != 2 || len(names) != 2 {
+		return fmt.Errorf("expecting Tag.state=expression, got %q", s)
+	}
+	r := Rule{Tag: names[0], State: names[1]}
+	var remove bool
+	if expr := strings.TrimSpace(tokens[1]); len(expr) == 0 {
+		remove = true
+	} else if m := reRule.FindStringSubmatch(expr); m == nil {
+		return fmt.Errorf("invalid expression %q", expr)
+	} else {
+		threshold, err := strconv.ParseFloat(m[3], 64)
+		if err != nil {
+			return err
+		}
+		r.Source, r.Operator, r.Threshold = m[1], m[2], threshold
+	}
+	for i, rule := range *l {
+		if rule.Tag == r.Tag && rule.State == r.State {
+			if remove {
+				*l = append((*l)[:i], (*l)[i + 1:]...)
+			} else {
+				(*l)[i] = r
+			}
+			return nil
+		}
+	}
+	if !(remove) {
+		*l = append(*l, r)
+	}
+	return nil
+}
+
+// The states are also the waybar classes: muted, playing, paused, stopped
+// and high load ones are set by default.
+var rules = ruleList{
+	{"CpuPercent", "high", "", ">", 80},
+	{"CpuPercent", "critical", "", ">", 95},
+	{"MemPercent", "high", "", ">", 80},
+	{"Volume", "muted", "VolumeMute", ">=", 1},
+	{"Mpris", "stopped", "MprisPlayback", "==", 0},
+	{"Mpris", "paused", "MprisPlayback", "==", 1},
+	{"Mpris", "playing", "MprisPlayback", "==", 2},
+	{"MprisProgress", "stopped", "MprisPlayback", "==", 0},
+	{"MprisProgress", "paused", "MprisPlayback", "==", 1},
+	{"MprisProgress", "playing", "MprisPlayback", "==", 2},
+}
+
+func init() {
+	flag.Var(
+		&rules,
+		"rule",
+		"state of a tag, like 'CpuPercent.high=>80' or " +
+			"'Volume.muted=VolumeMute>=1', empty to remove, the urgent state " +
+			"blinking (repeatable)",
+	)
+}
+
+// GetState returns the state of the tag, given the values.
+func GetState(tag string, values map[string]float64) (state string) {
+	for _, r := range rules {
+		if r.Tag == tag && r.Match(values) {
+			state = r.State
+		}
+	}
+	return
+}
+
+// setState updates the state of the tag, then its color and format, and
+// reports whether it changed.
+func (w *Widget) setState(tag string) bool {
+	ws := w.Statuses[tag]
+	state := GetState(tag, w.values)
+	if state == ws.State {
+		return false
+	}
+	ws.State = state
+	ws.Color = GetColor(tag, state)
+	ws.Format = w.formatter.Format(tag, ws.Color)
+	return true
+}
+
+// InitializeValues gets the values from the statuses daemon, if exported.
+func (w *Widget) InitializeValues(conn *dbus.Conn) {
+	var values map[string]dbus.Variant
+	obj := conn.Object(busName, dbus.ObjectPath(valuesPath))
+	if err := obj.Call(
+		propertiesIface + ".GetAll",
+		0,
+		valuesIface,
+	).Store(&values); err != nil {
+		if *debug {
+			fmt.Fprintln(os.Stderr, "Failed to get values:", err)
+		}
+		return
+	}
+	w.storeValues(values)
+}
+
+// ApplyValues updates the values from the body of a PropertiesChanged signal
+// and reports whether any state changed.
+func (w *Widget) ApplyValues(body []interface{}) bool {
+	if len(body) < 2 {
+		return false
+	}
+	if iface, ok := body[0].(string); !ok || iface != valuesIface {
+		return false
+	}
+	changed, ok := body[1].(map[string]dbus.Variant)
+	if !ok {
+		return false
+	}
+	return w.storeValues(changed)
+}
+
+func (w *Widget) storeValues(values map[string]dbus.Variant) (updated bool) {
+	for name, variant := range values {
+		var value float64
+		if err := variant.Store(&value); err == nil {
+			w.values[name] = value
+			if ws, found := w.Statuses[name]; found {
+				ws.Value = strconv.FormatFloat(value, 'f', -1, 64)
+			}
+		}
+	}
+	for _, tag := range w.ordered {
+		if w.setState(tag) {
+			updated = true
+		}
+	}
+	return
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
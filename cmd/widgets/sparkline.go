@@ -0,0 +1,174 @@
+package main
+
+import (
+	"flag"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Example: CpuPercent, with -sparkline CpuPercent
+//   92% ▁▁▂▃▅▇▇█
+
+var (
+	sparklines = flag.String(
+		"sparkline",
+		"",
+		"tags followed by the sparkline of their last values, " +
+			"like CpuPercent,MemPercent,DownSpeed,UpSpeed",
+	)
+	sparklineWidth = flag.Int("sparkline-width", 8, "sparkline width")
+	sparklineInterval = flag.Duration(
+		"sparkline-interval",
+		time.Second,
+		"interval between the values shown by sparklines",
+	)
+	sparklineStyle = flag.String(
+		"sparkline-style",
+		"blocks",
+		"sparkline style (blocks, braille)",
+	)
+	sparklineScale = keyValues{
+		"CpuPercent": "100",
+		"MemPercent": "100",
+		"MprisProgress": "100",
+	}
+)
+
+func init() {
+	flag.Var(
+		sparklineScale,
+		"sparkline-scale",
+		"maximum value of a tag sparkline, 0 to scale to the highest value " +
+			"shown (repeatable)",
+	)
+}
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// history keeps the last values of a tag.
+type history struct {
+	values []float64
+	size int
+}
+
+func newHistory() *history {
+	size := *sparklineWidth
+	if *sparklineStyle == "braille" {
+		// two values per braille character
+		size *= 2
+	}
+	return &history{size: size}
+}
+
+func (h *history) push(value float64) {
+	h.values = append(h.values, value)
+	if len(h.values) > h.size {
+		h.values = h.values[len(h.values) - h.size:]
+	}
+}
+
+// hasSparkline reports whether the tag is followed by a sparkline.
+func hasSparkline(tag string) bool {
+	for _, name := range strings.Split(*sparklines, ",") {
+		if strings.TrimSpace(name) == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// level returns the value scaled from 0 to levels.
+func level(value, max float64, levels int) int {
+	if max <= 0 || value <= 0 {
+		return 0
+	}
+	if value >= max {
+		return levels
+	}
+	return int(value / max * float64(levels) + 0.5)
+}
+
+// Sparkline renders the history of the tag, right aligned, scaled to the
+// configured maximum or to its highest value.
+func (h *history) Sparkline(tag string) string {
+	max, _ := strconv.ParseFloat(sparklineScale[tag], 64)
+	if max <= 0 {
+		for _, value := range h.values {
+			if value > max {
+				max = value
+			}
+		}
+	}
+	// pad with zeroes to keep the width
+	values := make([]float64, h.size - len(h.values), h.size)
+	values = append(values, h.values...)
+	var b strings.Builder
+	if *sparklineStyle == "braille" {
+		// dots filled from the bottom, left then right column
+		left := []rune{0, 0x40, 0x44, 0x46, 0x47}
+		right := []rune{0, 0x80, 0xa0, 0xb0, 0xb8}
+		for i := 0; i + 1 < len(values); i += 2 {
+			b.WriteRune(
+				0x2800 + left[level(values[i], max, 4)] +
+					right[level(values[i + 1], max, 4)],
+			)
+		}
+		return b.String()
+	}
+	for _, value := range values {
+		b.WriteRune(sparkBlocks[level(value, max, len(sparkBlocks) - 1)])
+	}
+	return b.String()
+}
+
+// appendSparkline appends the sparkline of the status to raw, when any,
+// colored by colorize when the highlight option is set.
+func appendSparkline(
+	raw string,
+	ws *widgetStatus,
+	colorize func(string) string,
+) string {
+	if len(ws.Sparkline) == 0 {
+		return raw
+	}
+	sparkline := ws.Sparkline
+	if *highlight && colorize != nil {
+		sparkline = colorize(sparkline)
+	}
+	return raw + " " + sparkline
+}
+
+// Sample adds the last values of the tags followed by a sparkline to their
+// history, and reports whether any sparkline changed.
+func (w *Widget) Sample() (updated bool) {
+	for _, tag := range w.ordered {
+		if value, found := w.values[tag]; found && w.pushValue(tag, value) {
+			updated = true
+		}
+	}
+	return
+}
+
+// pushValue adds the value to the history of the tag, if followed by a
+// sparkline, and reports whether the sparkline changed.
+func (w *Widget) pushValue(tag string, value float64) bool {
+	ws, found := w.Statuses[tag]
+	if !(found) || !(hasSparkline(tag)) {
+		return false
+	}
+	h, found := w.histories[tag]
+	if !(found) {
+		h = newHistory()
+		w.histories[tag] = h
+	}
+	h.push(value)
+	sparkline := h.Sparkline(tag)
+	if sparkline == ws.Sparkline {
+		return false
+	}
+	ws.Sparkline = sparkline
+	return true
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"strings"
+)
+
+// Example: polybar
+// -template 'CpuPercent=%{F{highlight}}{label}%{F-} {text}'
+// -template 'Mpris=[{label}]' -template '*={label} {text}'
+
+var templates = make(keyValues)
+
+func init() {
+	flag.Var(
+		templates,
+		"template",
+		"template of a tag, or * for all, using {label}, {text}, {tag}, " +
+			"{state}, {value}, {sparkline}, {background}, {highlight}, " +
+			"{background_rgb} and {highlight_rgb} (repeatable)",
+	)
+}
+
+// GetTemplate returns the template of the tag, if any.
+func GetTemplate(tag string) (template string, found bool) {
+	if template, found = templates[tag]; found {
+		return
+	}
+	template, found = templates["*"]
+	return
+}
+
+// RenderTemplate returns the content of the tag status from its template,
+// if any, the label and the text being escaped when required by the bar.
+// Unknown variables are left as is.
+func RenderTemplate(tag string, ws *widgetStatus, escape func(string) string) (
+	raw string,
+	found bool,
+) {
+	template, found := GetTemplate(tag)
+	if !(found) {
+		return
+	}
+	if escape == nil {
+		escape = func(s string) string { return s }
+	}
+	rgb := ws.Color.Rgb()
+	raw = strings.NewReplacer(
+		"{label}", escape(ws.Label),
+		"{text}", escape(ws.Text),
+		"{tag}", tag,
+		"{state}", ws.State,
+		"{value}", ws.Value,
+		"{sparkline}", ws.Sparkline,
+		"{background}", ws.Color.Background,
+		"{highlight}", ws.Color.Highlight,
+		"{background_rgb}", rgb.Background,
+		"{highlight_rgb}", rgb.Highlight,
+	).Replace(template)
+	return
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Example: CpuPercent
+// \x1b[4;48;2;204;0;0m \x1b[38;2;192;57;43m\x1b[39m  92% \x1b[0m
+
+var (
+	colors = flag.String(
+		"colors",
+		"auto",
+		"terminal colors (auto, truecolor, 256, 16, none)",
+	)
+	ttyOnly = flag.Bool(
+		"tty-only",
+		false,
+		"strip terminal styling when stdout is not a terminal",
+	)
+)
+
+// ansi16 holds the xterm default values of the 16 ANSI colors.
+var ansi16 = [16][3]int{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// ParseRgb returns the red, green and blue values of a #RRGGBB or #AARRGGBB
+// color, the alpha channel being ignored.
+func ParseRgb(color string) (rgb [3]int, ok bool) {
+	hex := strings.TrimPrefix(color, "#")
+	if len(hex) == 8 {
+		hex = hex[2:]
+	}
+	if len(hex) != 6 {
+		return
+	}
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return
+	}
+	return [3]int{int(value >> 16 & 0xff), int(value >> 8 & 0xff), int(value & 0xff)}, true
+}
+
+func distance(a, b [3]int) (d int) {
+	for i := range a {
+		d += (a[i] - b[i]) * (a[i] - b[i])
+	}
+	return
+}
+
+// To256 returns the nearest color of the xterm 256 colors palette, out of
+// the color cube and the grayscale ramp.
+func To256(rgb [3]int) int {
+	levels := []int{0, 95, 135, 175, 215, 255}
+	nearest := func(v int) (index int) {
+		for i, level := range levels {
+			if abs(v - level) < abs(v - levels[index]) {
+				index = i
+			}
+		}
+		return
+	}
+	r, g, b := nearest(rgb[0]), nearest(rgb[1]), nearest(rgb[2])
+	cube := [3]int{levels[r], levels[g], levels[b]}
+	gray := (rgb[0] + rgb[1] + rgb[2]) / 3
+	step := (gray - 8) / 10
+	if step < 0 {
+		step = 0
+	} else if step > 23 {
+		step = 23
+	}
+	level := 8 + step * 10
+	if distance(rgb, [3]int{level, level, level}) < distance(rgb, cube) {
+		return 232 + step
+	}
+	return 16 + 36 * r + 6 * g + b
+}
+
+// To16 returns the nearest of the 16 ANSI colors.
+func To16(rgb [3]int) (index int) {
+	for i, c := range ansi16 {
+		if distance(rgb, c) < distance(rgb, ansi16[index]) {
+			index = i
+		}
+	}
+	return
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// ColorMode returns the colors to use, from the colors flag, then from
+// COLORTERM and TERM environment variables.
+func ColorMode() string {
+	if *ttyOnly && !(isTerminal(os.Stdout)) {
+		return "none"
+	}
+	if *colors != "auto" {
+		return *colors
+	}
+	switch colorterm := os.Getenv("COLORTERM"); colorterm {
+	case "truecolor", "24bit":
+		return "truecolor"
+	}
+	term := os.Getenv("TERM")
+	switch {
+	case len(term) == 0, term == "dumb":
+		return "none"
+	case strings.Contains(term, "256color"):
+		return "256"
+	}
+	return "16"
+}
+
+type termFormatter struct {
+	baseFormatter
+	mode string
+}
+
+func newTermFormatter() Formatter {
+	return termFormatter{mode: ColorMode()}
+}
+
+// sgr returns the SGR parameters setting color as foreground, or background
+// when bg is true.
+func (f termFormatter) sgr(color string, bg bool) string {
+	rgb, ok := ParseRgb(color)
+	if !(ok) {
+		return ""
+	}
+	base := 38
+	if bg {
+		base = 48
+	}
+	switch f.mode {
+	case "truecolor":
+		return fmt.Sprintf("%d;2;%d;%d;%d", base, rgb[0], rgb[1], rgb[2])
+	case "256":
+		return fmt.Sprintf("%d;5;%d", base, To256(rgb))
+	case "16":
+		index := To16(rgb)
+		code := 30 + index % 8
+		if index >= 8 {
+			code += 60
+		}
+		if bg {
+			code += 10
+		}
+		return strconv.Itoa(code)
+	}
+	return ""
+}
+
+func (f termFormatter) Format(tag string, c Color) (format string) {
+	format = Padded("[CONTENT]")
+	if f.mode == "none" {
+		return
+	}
+	var params []string
+	if *background {
+		if p := f.sgr(c.Background, true); len(p) > 0 {
+			params = append(params, p)
+		}
+	}
+	switch *border {
+	case `overline`:
+		params = append(params, "53")
+	case `underline`:
+		params = append(params, "4")
+	}
+	if len(params) > 0 {
+		format = "\x1b[" + strings.Join(params, ";") + "m" + format + "\x1b[0m"
+	}
+	return
+}
+
+func (f termFormatter) Content(tag string, ws *widgetStatus) string {
+	if raw, found := RenderTemplate(tag, ws, nil); found {
+		return raw
+	}
+	raw := ws.Text
+	if !(*text) && len(ws.Label) > 0 {
+		p := f.sgr(ws.Color.Highlight, false)
+		if *highlight && f.mode != "none" && len(p) > 0 {
+			raw = fmt.Sprintf("\x1b[%sm%s\x1b[39m %s", p, ws.Label, raw)
+		} else {
+			raw = fmt.Sprint(ws.Label, raw)
+		}
+	}
+	return appendSparkline(raw, ws, func(s string) string {
+		p := f.sgr(ws.Color.Highlight, false)
+		if f.mode == "none" || len(p) == 0 {
+			return s
+		}
+		return "\x1b[" + p + "m" + s + "\x1b[39m"
+	})
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Example: Xresources
+// *.color1: #cc241d
+// gostatuses.CpuPercent.background: #7fcc0000
+// gostatuses.CpuPercent.high.highlight: color9
+// gostatuses.default.highlight: foreground
+// gostatuses.Mpris.template: [{label}]
+
+var theme = flag.String(
+	"theme",
+	"",
+	"theme file, Xresources, base16 YAML (.yaml) or pywal JSON (.json)",
+)
+
+// palettes maps the tags to the palette colors, pywal or Xresources ones
+// first, then base16 ones, used when the theme does not define them.
+var palettes = map[string][]string{
+	"CpuPercent": {"color1", "base08"},
+	"CpuFreq": {"color1", "base08"},
+	"LoadAvg": {"color1", "base08"},
+	"MemPercent": {"color5", "base0E"},
+	"SwapUsed": {"color5", "base0E"},
+	"NetDevice": {"color2", "base0B"},
+	"DownSpeed": {"color3", "base09"},
+	"DownTotal": {"color3", "base09"},
+	"UpSpeed": {"color11", "base0A"},
+	"UpTotal": {"color11", "base0A"},
+	"Volume": {"color2", "base0B"},
+	"Mpris": {"color2", "base0B"},
+	"MprisProgress": {"color2", "base0B"},
+	"MprisPlayers": {"color2", "base0B"},
+	"*": {"color8", "base03"},
+}
+
+// GetColor returns the color of the tag in the given state, falling back
+// to the default color of the state, then to the tag and default colors.
+func GetColor(tag, state string) Color {
+	keys := []string{tag, "*"}
+	if len(state) > 0 {
+		keys = []string{tag + "." + state, "*." + state, tag, "*"}
+	}
+	for _, key := range keys {
+		if c, found := defaultColors[key]; found {
+			return c
+		}
+	}
+	return Color{}
+}
+
+// LoadTheme returns the resources defined in a theme file, with gostatuses
+// prefixes removed.
+func LoadTheme(path string) (resources map[string]string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	switch filepath.Ext(path) {
+	case ".json":
+		return readPywal(f)
+	case ".yaml", ".yml":
+		return readBase16(f)
+	}
+	return readXresources(f)
+}
+
+func readXresources(r io.Reader) (resources map[string]string, err error) {
+	resources = make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// skip comments and preprocessor directives
+		if len(line) == 0 || line[0] == '!' || line[0] == '#' {
+			continue
+		}
+		tokens := strings.SplitN(line, ":", 2)
+		if len(tokens) != 2 {
+			continue
+		}
+		name := strings.TrimLeft(strings.TrimSpace(tokens[0]), "*.")
+		for _, prefix := range []string{"gostatuses.", "gostatuses*"} {
+			name = strings.TrimPrefix(name, prefix)
+		}
+		resources[name] = strings.TrimSpace(tokens[1])
+	}
+	err = scanner.Err()
+	return
+}
+
+// readBase16 reads the flat key: value pairs of a base16 scheme, adding the
+// missing # to the baseXX colors.
+func readBase16(r io.Reader) (resources map[string]string, err error) {
+	resources = make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		tokens := strings.SplitN(line, ":", 2)
+		if len(tokens) != 2 {
+			continue
+		}
+		name := strings.TrimSpace(tokens[0])
+		value := strings.Trim(strings.TrimSpace(tokens[1]), `"'`)
+		if strings.HasPrefix(name, "base") && len(value) == 6 {
+			value = "#" + value
+		}
+		resources[name] = value
+	}
+	err = scanner.Err()
+	return
+}
+
+// readPywal reads a pywal colors.json file, where tag colors may be set in
+// an extra gostatuses object.
+func readPywal(r io.Reader) (resources map[string]string, err error) {
+	var scheme struct {
+		Special map[string]string `json:"special"`
+		Colors map[string]string `json:"colors"`
+		Gostatuses map[string]string `json:"gostatuses"`
+	}
+	if err = json.NewDecoder(r).Decode(&scheme); err != nil {
+		return
+	}
+	resources = make(map[string]string)
+	for _, m := range []map[string]string{
+		scheme.Special,
+		scheme.Colors,
+		scheme.Gostatuses,
+	} {
+		for name, value := range m {
+			resources[name] = value
+		}
+	}
+	return
+}
+
+// translucent returns the #AARRGGBB background for a #RRGGBB color.
+func translucent(color string) string {
+	if len(color) == 7 {
+		return "#5f" + color[1:]
+	}
+	return color
+}
+
+// ApplyTheme sets the tag colors from the palette, then from the tag
+// resources, named like Tag.background or Tag.state.highlight, the default
+// tag being named default. Values may refer to palette colors. Tag.template
+// resources set the templates not given on the command line.
+func ApplyTheme(resources map[string]string) {
+	resolve := func(value string) string {
+		if strings.HasPrefix(value, "#") {
+			return value
+		}
+		if color, found := resources[value]; found {
+			return color
+		}
+		return value
+	}
+	for tag, names := range palettes {
+		for _, name := range names {
+			if color, found := resources[name]; found {
+				color = resolve(color)
+				defaultColors[tag] = Color{translucent(color), color}
+				break
+			}
+		}
+	}
+	var names []string
+	for name, value := range resources {
+		if tokens := strings.Split(name, "."); len(tokens) == 2 &&
+			tokens[1] == "template" {
+			if tokens[0] == "default" {
+				tokens[0] = "*"
+			}
+			if _, found := templates[tokens[0]]; !(found) {
+				templates[tokens[0]] = value
+			}
+			continue
+		}
+		if strings.HasSuffix(name, ".background") ||
+			strings.HasSuffix(name, ".highlight") {
+			names = append(names, name)
+		}
+	}
+	// tag colors first, then state variants built upon them
+	sort.Slice(names, func(i, j int) bool {
+		ni, nj := strings.Count(names[i], "."), strings.Count(names[j], ".")
+		if ni != nj {
+			return ni < nj
+		}
+		return names[i] < names[j]
+	})
+	for _, name := range names {
+		tokens := strings.Split(name, ".")
+		// resources of other applications
+		if len(tokens) > 3 {
+			continue
+		}
+		if tokens[0] == "default" {
+			tokens[0] = "*"
+		}
+		key := strings.Join(tokens[:len(tokens) - 1], ".")
+		c, found := defaultColors[key]
+		if !(found) {
+			c = GetColor(tokens[0], "")
+		}
+		switch value := resolve(resources[name]); tokens[len(tokens) - 1] {
+		case "background":
+			c.Background = value
+		case "highlight":
+			c.Highlight = value
+		}
+		defaultColors[key] = c
+	}
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
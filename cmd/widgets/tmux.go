@@ -0,0 +1,41 @@
+package main
+
+// Example: CpuPercent
+// #[fg=#c0392b,bg=#cc0000]   92% #[fg=default,bg=default]
+
+type tmuxFormatter struct {
+	baseFormatter
+}
+
+func (f tmuxFormatter) Format(tag string, c Color) (format string) {
+	var fg string = "default"
+	var bg string = "default"
+	if *highlight {
+		fg = c.Rgb().Highlight  // "#ffffff"
+	}
+	if withBackground() {
+		bg = c.Rgb().Background  // "#000000"
+	}
+	format = "#[fg=" + fg + ",bg=" + bg + "]" + Padded("[CONTENT]")
+	format += "#[fg=default,bg=default]"
+	return
+}
+
+// Join joins the segments with powerline glyphs when required.
+func (f tmuxFormatter) Join(w *Widget, items []string) string {
+	if !(powerline()) {
+		return f.baseFormatter.Join(w, items)
+	}
+	return PowerlineJoin(w, items, func(fg, bg string) string {
+		fg = Color{Background: fg}.Rgb().Background
+		if len(bg) == 0 {
+			bg = "default"
+		} else {
+			bg = Color{Background: bg}.Rgb().Background
+		}
+		return "#[fg=" + fg + ",bg=" + bg + "]" + *powerlineGlyph +
+			"#[fg=default,bg=default]"
+	})
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
@@ -0,0 +1,7 @@
+package main
+
+import "golang.org/x/sys/unix"
+
+const ioctlReadTermios = unix.TCGETS
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
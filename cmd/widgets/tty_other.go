@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package main
+
+import "os"
+
+// isTerminal reports false where terminals are not detected, the colors
+// being then set by the colors option.
+func isTerminal(f *os.File) bool {
+	return false
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
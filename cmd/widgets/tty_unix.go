@@ -0,0 +1,18 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package main
+
+import (
+	"os"
+	"golang.org/x/sys/unix"
+)
+
+// isTerminal reports whether f is a terminal, the request reading its
+// attributes being ioctlReadTermios.
+func isTerminal(f *os.File) bool {
+	_, err := unix.IoctlGetTermios(int(f.Fd()), ioctlReadTermios)
+	return err == nil
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Example: Volume
+// {"text":" 42%","tooltip":"Volume:  42%","class":["Volume"],"percentage":42}
+
+var rePercent = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*%`)
+
+// GetPercentage returns the percentage found in the text of the status.
+func GetPercentage(s DbusStatus) (percentage int, found bool) {
+	if m := rePercent.FindStringSubmatch(s.Text); m != nil {
+		if value, err := strconv.ParseFloat(m[1], 64); err == nil {
+			return int(value + 0.5), true
+		}
+	}
+	return
+}
+
+// GetClasses returns the tag, followed by the state of the status set by
+// the rules, if any.
+func GetClasses(tag string, ws *widgetStatus) (classes []string) {
+	classes = append(classes, tag)
+	if len(ws.State) > 0 {
+		classes = append(classes, ws.State)
+	}
+	return
+}
+
+type waybarOutput struct {
+	Text string `json:"text"`
+	Tooltip string `json:"tooltip,omitempty"`
+	Class []string `json:"class"`
+	Percentage *int `json:"percentage,omitempty"`
+}
+
+type waybarFormatter struct {
+	baseFormatter
+}
+
+func (f waybarFormatter) Format(tag string, c Color) string {
+	return "[CONTENT]"
+}
+
+// Urgent reports that waybar styles the urgent class itself, with a CSS
+// animation for instance.
+func (f waybarFormatter) Urgent() bool {
+	return true
+}
+
+// Join returns one JSON object for all the statuses.
+func (f waybarFormatter) Join(w *Widget, items []string) string {
+	output := waybarOutput{Text: f.baseFormatter.Join(w, items)}
+	var tooltip []string
+	for _, tag := range w.ordered {
+		s := w.GetStatus(tag)
+		tooltip = append(
+			tooltip,
+			strings.TrimSpace(tag + ": " + s.Label + " " + s.Text),
+		)
+		output.Class = append(output.Class, GetClasses(tag, w.Statuses[tag])...)
+		if percentage, found := GetPercentage(s); found && output.Percentage == nil {
+			output.Percentage = &percentage
+		}
+	}
+	output.Tooltip = strings.Join(tooltip, "\n")
+	data, _ := json.Marshal(output)
+	return string(data)
+}
+
+func (f waybarFormatter) Placeholder(raw string) string {
+	data, _ := json.Marshal(waybarOutput{Text: raw, Class: []string{"offline"}})
+	return string(data)
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet:
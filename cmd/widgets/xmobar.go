@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Example: CpuPercent
+// <box type=Top color=#c0392b><fc=#ffffff,#cc0000> <raw=6:  92%/> </fc></box>
+
+var foreground = flag.String(
+	"foreground",
+	"#ffffff",
+	"text color, when xmobar requires one to set a background",
+)
+
+type xmobarFormatter struct {
+	baseFormatter
+}
+
+func (f xmobarFormatter) Format(tag string, c Color) (format string) {
+	format = Padded("[CONTENT]")
+	if *background {
+		format = "<fc=" + *foreground + "," + c.Rgb().Background + ">" +
+			format + "</fc>"
+	}
+	switch *border {
+	case `overline`:
+		format = "<box type=Top color=" + c.Rgb().Highlight + ">" + format + "</box>"
+	case `underline`:
+		format = "<box type=Bottom color=" + c.Rgb().Highlight + ">" + format +
+			"</box>"
+	}
+	return
+}
+
+// raw protects text from being parsed as xmobar markup.
+func raw(text string) string {
+	if len(text) == 0 {
+		return text
+	}
+	return fmt.Sprintf("<raw=%d:%s/>", utf8.RuneCountInString(text), text)
+}
+
+func (f xmobarFormatter) Content(tag string, ws *widgetStatus) string {
+	if content, found := RenderTemplate(tag, ws, raw); found {
+		return content
+	}
+	content := raw(ws.Text)
+	if !(*text) && len(ws.Label) > 0 {
+		if *highlight {
+			content = fmt.Sprintf(
+				"<fc=%s>%s</fc> %s",
+				ws.Color.Rgb().Highlight,
+				raw(ws.Label),
+				content,
+			)
+		} else {
+			content = raw(ws.Label + ws.Text)
+		}
+	}
+	return appendSparkline(content, ws, func(s string) string {
+		return fmt.Sprintf("<fc=%s>%s</fc>", ws.Color.Rgb().Highlight, s)
+	})
+}
+
+func (f xmobarFormatter) Placeholder(text string) string {
+	return raw(text)
+}
+
+// vim: set ft=go fdm=indent ts=2 sw=2 tw=79 noet: